@@ -5,5 +5,8 @@ go 1.14
 require (
 	github.com/go-kit/kit v0.9.0
 	github.com/prometheus/client_golang v1.5.1
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.9.1
 	github.com/urfave/cli/v2 v2.2.0
+	gopkg.in/yaml.v2 v2.2.5
 )
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// StatusCache caches the parsed status files, a file is parsed again only once its
+// modification time or size changed. It is kept across configuration reloads, files of
+// servers which are no longer configured are dropped by Retain.
+type StatusCache struct {
+	mu    sync.Mutex
+	files map[string]*cachedStatus
+}
+
+// CacheStats contains the amount of status reads of a server answered from the cache and
+// the amount of its reads which parsed the status file
+type CacheStats struct {
+	Hits   float64
+	Parses float64
+}
+
+type cachedStatus struct {
+	// mu serializes the reads of a file, concurrent scrapes wait for a single parse
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	status  *openvpn.Status
+	// stats are kept by server, servers sharing a status file count their own reads
+	stats map[string]*CacheStats
+}
+
+// NewStatusCache returns a new StatusCache
+func NewStatusCache() *StatusCache {
+	return &StatusCache{
+		files: make(map[string]*cachedStatus),
+	}
+}
+
+// Status returns the cached status of the file if it did not change since it was parsed and
+// parses it otherwise, the read is counted for the server. Errors are not cached.
+func (c *StatusCache) Status(server, path string) (*openvpn.Status, error) {
+	cached := c.file(path)
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stats := cached.serverStats(server)
+	if cached.status != nil && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		stats.Hits++
+		return cached.status, nil
+	}
+	stats.Parses++
+	status, err := parseStatusFile(path)
+	if err != nil {
+		cached.status = nil
+		return nil, err
+	}
+	// the file info is taken before parsing, a file rewritten meanwhile is parsed again
+	cached.modTime = info.ModTime()
+	cached.size = info.Size()
+	cached.status = status
+	return status, nil
+}
+
+// Stats returns the cache hits and parses of the file by the server
+func (c *StatusCache) Stats(server, path string) CacheStats {
+	cached := c.file(path)
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	return *cached.serverStats(server)
+}
+
+// Retain drops the files and stats which are not read through the cache by the servers
+func (c *StatusCache) Retain(servers []OpenVPNServer) {
+	retained := make(map[string]map[string]bool)
+	for _, server := range servers {
+		if !server.cachesStatus() {
+			continue
+		}
+		if retained[server.StatusFile] == nil {
+			retained[server.StatusFile] = make(map[string]bool)
+		}
+		retained[server.StatusFile][server.Name] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, cached := range c.files {
+		names, ok := retained[path]
+		if !ok {
+			delete(c.files, path)
+			continue
+		}
+		cached.mu.Lock()
+		for name := range cached.stats {
+			if !names[name] {
+				delete(cached.stats, name)
+			}
+		}
+		cached.mu.Unlock()
+	}
+}
+
+func (c *StatusCache) file(path string) *cachedStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.files[path]
+	if !ok {
+		cached = &cachedStatus{stats: make(map[string]*CacheStats)}
+		c.files[path] = cached
+	}
+	return cached
+}
+
+func (c *cachedStatus) serverStats(server string) *CacheStats {
+	stats, ok := c.stats[server]
+	if !ok {
+		stats = &CacheStats{}
+		c.stats[server] = stats
+	}
+	return stats
+}
+
+// ConfigCache caches the parsed openvpn config files and the lease counts of their
+// ifconfig-pool-persist files, a file is read again only once its modification time or size
+// changed. It is kept across configuration reloads, files of servers which are no longer
+// configured are dropped by Retain.
+type ConfigCache struct {
+	mu    sync.Mutex
+	files map[string]*cachedFile
+}
+
+type cachedFile struct {
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	value   interface{}
+}
+
+// NewConfigCache returns a new ConfigCache
+func NewConfigCache() *ConfigCache {
+	return &ConfigCache{
+		files: make(map[string]*cachedFile),
+	}
+}
+
+// Config returns the parsed openvpn config file. Errors are not cached.
+func (c *ConfigCache) Config(path string) (*openvpn.Config, error) {
+	value, err := c.read(path, func(path string) (interface{}, error) {
+		return openvpn.ParseConfigFile(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*openvpn.Config), nil
+}
+
+// PersistedLeases returns the amount of leases of the ifconfig-pool-persist file. Errors are
+// not cached.
+func (c *ConfigCache) PersistedLeases(path string) (int, error) {
+	value, err := c.read(path, func(path string) (interface{}, error) {
+		return openvpn.CountPersistedLeases(path)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value.(int), nil
+}
+
+// Retain drops the files which are not read by the servers
+func (c *ConfigCache) Retain(servers []OpenVPNServer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	retained := make(map[string]bool)
+	for _, server := range servers {
+		if server.ConfigFile == "" {
+			continue
+		}
+		retained[server.ConfigFile] = true
+		if cached, ok := c.files[server.ConfigFile]; ok {
+			cached.mu.Lock()
+			if config, ok := cached.value.(*openvpn.Config); ok && config.IfconfigPoolPersist != "" {
+				retained[config.IfconfigPoolPersist] = true
+			}
+			cached.mu.Unlock()
+		}
+	}
+	for path := range c.files {
+		if !retained[path] {
+			delete(c.files, path)
+		}
+	}
+}
+
+func (c *ConfigCache) read(path string, parse func(string) (interface{}, error)) (interface{}, error) {
+	cached := c.file(path)
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if cached.value != nil && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.value, nil
+	}
+	value, err := parse(path)
+	if err != nil {
+		cached.value = nil
+		return nil, err
+	}
+	cached.modTime = info.ModTime()
+	cached.size = info.Size()
+	cached.value = value
+	return value, nil
+}
+
+func (c *ConfigCache) file(path string) *cachedFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.files[path]
+	if !ok {
+		cached = &cachedFile{}
+		c.files[path] = cached
+	}
+	return cached
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestStatusCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	v2, err := ioutil.ReadFile("../../example/version2.status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, err := ioutil.ReadFile("../../example/version3.status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusFile := filepath.Join(dir, "server.status")
+	cache := NewStatusCache()
+
+	if _, err := cache.Status("server", statusFile); err == nil {
+		t.Errorf("should have failed on a missing status file")
+	}
+	if err := ioutil.WriteFile(statusFile, v2, 0644); err != nil {
+		t.Fatal(err)
+	}
+	first, err := cache.Status("server", statusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.Status("server", statusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("expected the unchanged status file to be answered from the cache")
+	}
+	if stats := cache.Stats("server", statusFile); stats.Hits != 1 || stats.Parses != 1 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+
+	if err := ioutil.WriteFile(statusFile, v3, 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(statusFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	third, err := cache.Status("server", statusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == second {
+		t.Errorf("expected the rewritten status file to be parsed again")
+	}
+	if stats := cache.Stats("server", statusFile); stats.Hits != 1 || stats.Parses != 2 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestStatusReads(t *testing.T) {
+	cache := NewStatusCache()
+	collector := NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{
+			{Name: "v2", StatusFile: "../../example/version2.status", StatusCache: cache},
+			{Name: "shared", StatusFile: "../../example/version2.status", StatusCache: cache},
+			{Name: "uncached", StatusFile: "../../example/version3.status"},
+		},
+		false, false, false, nil, nil,
+	)
+	gatherFamilies(t, collector)
+	families := gatherFamilies(t, collector)
+	reads := make(map[string]float64)
+	for _, metric := range families["openvpn_status_reads_total"].GetMetric() {
+		labels := labelMap(metric.GetLabel())
+		reads[labels["server"]+"/"+labels["result"]] = metric.GetCounter().GetValue()
+	}
+	// servers sharing a status file count their own reads
+	expected := map[string]float64{"v2/cache_hit": 1, "v2/parse": 1, "shared/cache_hit": 2, "shared/parse": 0}
+	if len(reads) != len(expected) {
+		t.Fatalf("unexpected status reads: %v", reads)
+	}
+	for key, value := range expected {
+		if reads[key] != value {
+			t.Errorf("expected %v status reads of %s, got %v", value, key, reads[key])
+		}
+	}
+}
+
+func TestStatusCacheRetain(t *testing.T) {
+	cache := NewStatusCache()
+	for _, read := range []struct{ server, path string }{
+		{"v2", "../../example/version2.status"},
+		{"shared", "../../example/version2.status"},
+		{"v3", "../../example/version3.status"},
+	} {
+		if _, err := cache.Status(read.server, read.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.Retain([]OpenVPNServer{
+		{Name: "v2", StatusFile: "../../example/version2.status", StatusCache: cache},
+		{Name: "v3", StatusFile: "../../example/version3.status", ManagementAddress: "127.0.0.1:7505", StatusCache: cache},
+	})
+	if len(cache.files) != 1 {
+		t.Errorf("expected only the status file read through the cache to be kept, got %v", cache.files)
+	}
+	if stats := cache.Stats("v2", "../../example/version2.status"); stats.Parses != 1 {
+		t.Errorf("expected the stats of the configured server to be kept, got %+v", stats)
+	}
+	if stats := cache.Stats("shared", "../../example/version2.status"); stats.Hits != 0 || stats.Parses != 0 {
+		t.Errorf("expected the stats of the removed server to be dropped, got %+v", stats)
+	}
+}
+
+func TestConfigCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "server.conf")
+	leasesFile := filepath.Join(dir, "ipp.txt")
+	if err := ioutil.WriteFile(configFile, []byte("server 10.8.0.0 255.255.255.0\nifconfig-pool-persist ipp.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(leasesFile, []byte("user1,10.8.0.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewConfigCache()
+
+	first, err := cache.Config(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.Config(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("expected the unchanged config file to be answered from the cache")
+	}
+	if leases, err := cache.PersistedLeases(first.IfconfigPoolPersist); err != nil || leases != 1 {
+		t.Errorf("expected 1 persisted lease, got %d: %v", leases, err)
+	}
+
+	if err := ioutil.WriteFile(leasesFile, []byte("user1,10.8.0.4\nuser2,10.8.0.8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if leases, err := cache.PersistedLeases(first.IfconfigPoolPersist); err != nil || leases != 2 {
+		t.Errorf("expected the rewritten leases file to be read again, got %d: %v", leases, err)
+	}
+	if err := ioutil.WriteFile(configFile, []byte("server 10.9.0.0 255.255.255.0\nifconfig-pool-persist ipp.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(configFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	third, err := cache.Config(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == second {
+		t.Errorf("expected the rewritten config file to be parsed again")
+	}
+
+	cache.Retain([]OpenVPNServer{{Name: "server", ConfigFile: configFile}})
+	if len(cache.files) != 2 {
+		t.Errorf("expected the config and leases file of the configured server to be kept, got %v", cache.files)
+	}
+	cache.Retain(nil)
+	if len(cache.files) != 0 {
+		t.Errorf("expected the files of removed servers to be dropped, got %v", cache.files)
+	}
+}
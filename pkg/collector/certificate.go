@@ -0,0 +1,222 @@
+package collector
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CertificateCollector collects the expiry of the certificates and CRLs of the servers, read from
+// the ca, cert and crl-verify directives of their openvpn config, and of additional PEM files
+type CertificateCollector struct {
+	logger                 log.Logger
+	files                  []string
+	extraLabels            []string
+	OpenVPNServer          []OpenVPNServer
+	CertificateNotAfter    *prometheus.Desc
+	CRLNextUpdate          *prometheus.Desc
+	CRLRevokedCertificates *prometheus.Desc
+	CollectionError        *prometheus.CounterVec
+}
+
+// pemSource is a PEM file, or inline PEM data of an openvpn config, to collect metrics from
+type pemSource struct {
+	server OpenVPNServer
+	kind   string
+	file   string
+	data   []byte
+}
+
+// NewCertificateCollector returns a new CertificateCollector
+func NewCertificateCollector(logger log.Logger, openVPNServer []OpenVPNServer, files []string) *CertificateCollector {
+	extraLabels := extraLabelNames(openVPNServer)
+	labels := func(names ...string) []string {
+		return append(names, extraLabels...)
+	}
+	return &CertificateCollector{
+		logger:        logger,
+		files:         files,
+		extraLabels:   extraLabels,
+		OpenVPNServer: openVPNServer,
+
+		CertificateNotAfter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "certificate_not_after_seconds"),
+			"Unix timestamp when the certificate expires",
+			labels("server", "type", "file", "subject", "serial"),
+			nil,
+		),
+		CRLNextUpdate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "crl_next_update_seconds"),
+			"Unix timestamp of the next update of the CRL, after which openvpn rejects all clients",
+			labels("server", "file", "issuer"),
+			nil,
+		),
+		CRLRevokedCertificates: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "crl_revoked_certificates"),
+			"Amount of revoked certificates in the CRL",
+			labels("server", "file", "issuer"),
+			nil,
+		),
+		CollectionError: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: prometheus.BuildFQName(namespace, "", "certificate_collection_error"),
+				Help: "Error occurred while reading a certificate or CRL",
+			},
+			[]string{"file"},
+		),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics collected by this Collector.
+func (c *CertificateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.CertificateNotAfter
+	ch <- c.CRLNextUpdate
+	ch <- c.CRLRevokedCertificates
+	c.CollectionError.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (c *CertificateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, source := range c.sources() {
+		if err := c.collect(source, ch); err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "error reading certificate",
+				"name", source.server.Name,
+				"file", source.file,
+				"err", err,
+			)
+			c.CollectionError.WithLabelValues(source.file).Inc()
+		}
+	}
+	c.CollectionError.Collect(ch)
+}
+
+// sources returns the PEM files referenced by the openvpn configs of the servers followed by
+// the additional files
+func (c *CertificateCollector) sources() []pemSource {
+	var sources []pemSource
+	for _, ovpn := range c.OpenVPNServer {
+		if ovpn.ConfigFile == "" {
+			continue
+		}
+		config, err := ovpn.openVPNConfig()
+		if err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "error parsing openvpn config",
+				"name", ovpn.Name,
+				"configFile", ovpn.ConfigFile,
+				"err", err,
+			)
+			c.CollectionError.WithLabelValues(ovpn.ConfigFile).Inc()
+			continue
+		}
+		for _, directive := range []struct {
+			kind string
+			file string
+		}{
+			{"ca", config.CA},
+			{"cert", config.Cert},
+			{"crl", config.CRLVerify},
+		} {
+			inlineTag := directive.kind
+			if inlineTag == "crl" {
+				inlineTag = "crl-verify"
+			}
+			if inline, ok := config.Inline[inlineTag]; ok {
+				sources = append(sources, pemSource{
+					server: ovpn,
+					kind:   directive.kind,
+					file:   ovpn.ConfigFile + "#" + inlineTag,
+					data:   []byte(inline),
+				})
+			} else if directive.file != "" {
+				sources = append(sources, pemSource{server: ovpn, kind: directive.kind, file: directive.file})
+			}
+		}
+	}
+	for _, file := range c.files {
+		sources = append(sources, pemSource{kind: "file", file: file})
+	}
+	return sources
+}
+
+func (c *CertificateCollector) collect(source pemSource, ch chan<- prometheus.Metric) error {
+	data := source.data
+	if data == nil {
+		var err error
+		data, err = ioutil.ReadFile(source.file)
+		if err != nil {
+			return err
+		}
+	}
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return err
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.CertificateNotAfter,
+				prometheus.GaugeValue,
+				float64(cert.NotAfter.Unix()),
+				c.labelValues(
+					source.server,
+					source.server.Name,
+					source.kind,
+					source.file,
+					cert.Subject.String(),
+					fmt.Sprintf("%x", cert.SerialNumber),
+				)...,
+			)
+		case "X509 CRL":
+			crl, err := x509.ParseCRL(block.Bytes)
+			if err != nil {
+				return err
+			}
+			labels := c.labelValues(
+				source.server,
+				source.server.Name,
+				source.file,
+				crl.TBSCertList.Issuer.String(),
+			)
+			if !crl.TBSCertList.NextUpdate.IsZero() {
+				ch <- prometheus.MustNewConstMetric(
+					c.CRLNextUpdate,
+					prometheus.GaugeValue,
+					float64(crl.TBSCertList.NextUpdate.Unix()),
+					labels...,
+				)
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.CRLRevokedCertificates,
+				prometheus.GaugeValue,
+				float64(len(crl.TBSCertList.RevokedCertificates)),
+				labels...,
+			)
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return errors.New("no certificate or CRL found")
+	}
+	return nil
+}
+
+func (c *CertificateCollector) labelValues(ovpn OpenVPNServer, values ...string) []string {
+	return extraLabelValues(c.extraLabels, ovpn, values)
+}
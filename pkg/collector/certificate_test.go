@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+var (
+	caNotAfter     = time.Unix(2000000000, 0)
+	crlNextUpdate  = time.Unix(1900000000, 0)
+	serverNotAfter = time.Unix(1950000000, 0)
+)
+
+// writeCertificates writes a CA, a server certificate, a CRL revoking two certificates and an
+// openvpn config referencing the CA and CRL and inlining the server certificate to dir
+func writeCertificates(t *testing.T, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Unix(1500000000, 0),
+		NotAfter:              caNotAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(0xbeef),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Unix(1500000000, 0),
+		NotAfter:     serverNotAfter,
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	crlDER, err := ca.CreateCRL(rand.Reader, key, []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(2), RevocationTime: time.Unix(1600000000, 0)},
+		{SerialNumber: big.NewInt(3), RevocationTime: time.Unix(1600000000, 0)},
+	}, time.Unix(1600000000, 0), crlNextUpdate)
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+
+	files := map[string][]byte{
+		"ca.crt":  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		"crl.pem": pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlDER}),
+		"server.conf": []byte("ca ca.crt\ncrl-verify crl.pem\n<cert>\n" +
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER})) + "</cert>\n"),
+		"invalid.pem": []byte("no certificate"),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestCertificateCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeCertificates(t, dir)
+
+	families := gatherFamilies(t, NewCertificateCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{{Name: "test", ConfigFile: filepath.Join(dir, "server.conf")}},
+		[]string{filepath.Join(dir, "ca.crt"), filepath.Join(dir, "invalid.pem")},
+	))
+
+	notAfter := make(map[string]float64)
+	for _, metric := range families["openvpn_certificate_not_after_seconds"].GetMetric() {
+		labels := make(map[string]string)
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		notAfter[labels["server"]+"|"+labels["type"]+"|"+labels["subject"]] = metric.GetGauge().GetValue()
+	}
+	expected := map[string]float64{
+		"test|ca|CN=Test CA":  float64(caNotAfter.Unix()),
+		"test|cert|CN=server": float64(serverNotAfter.Unix()),
+		"|file|CN=Test CA":    float64(caNotAfter.Unix()),
+	}
+	if len(notAfter) != len(expected) {
+		t.Errorf("Unexpected certificates: %v", notAfter)
+	}
+	for key, value := range expected {
+		if notAfter[key] != value {
+			t.Errorf("expected %s to expire at %v, got %v", key, value, notAfter[key])
+		}
+	}
+	if value := families["openvpn_crl_next_update_seconds"].GetMetric()[0].GetGauge().GetValue(); value != float64(crlNextUpdate.Unix()) {
+		t.Errorf("next update is not collected correctly: %v", value)
+	}
+	if value := families["openvpn_crl_revoked_certificates"].GetMetric()[0].GetGauge().GetValue(); value != 2 {
+		t.Errorf("revoked certificates are not collected correctly: %v", value)
+	}
+	errors := families["openvpn_certificate_collection_error"].GetMetric()
+	if len(errors) != 1 || errors[0].GetLabel()[0].GetValue() != filepath.Join(dir, "invalid.pem") {
+		t.Errorf("invalid file is not reported")
+	}
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// sessionDurationBuckets are the upper bounds of the session duration histogram in seconds,
+// from flapping clients to sessions lasting a week
+var sessionDurationBuckets = []float64{10, 30, 60, 300, 900, 1800, 3600, 4 * 3600, 8 * 3600, 24 * 3600, 7 * 24 * 3600}
+
+// SessionChurn detects connected and disconnected sessions by comparing consecutive status
+// snapshots of the servers. It is kept across configuration reloads.
+type SessionChurn struct {
+	mu      sync.Mutex
+	servers map[string]*serverChurn
+}
+
+// Churn contains the amount of connected and disconnected sessions of a server and the
+// duration histogram of the disconnected sessions
+type Churn struct {
+	Connects      float64
+	Disconnects   float64
+	DurationCount uint64
+	DurationSum   float64
+	// DurationBuckets contains the cumulative count per upper bound
+	DurationBuckets map[float64]uint64
+}
+
+type serverChurn struct {
+	Churn
+	// clients contains the clients of the previous snapshot
+	clients []openvpn.Client
+}
+
+// NewSessionChurn returns a new SessionChurn
+func NewSessionChurn() *SessionChurn {
+	return &SessionChurn{
+		servers: make(map[string]*serverChurn),
+	}
+}
+
+// Observe compares the clients of a server with its previous snapshot and returns the
+// accumulated churn. Sessions of the first snapshot are not counted as connects. A session
+// is disconnected once it disappears or its connected since changes, its duration is measured
+// until the snapshot it was missing from was updated or until it reconnected.
+func (s *SessionChurn) Observe(server string, clients []openvpn.Client, updatedAt time.Time) Churn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	churn, ok := s.servers[server]
+	if !ok {
+		churn = &serverChurn{
+			Churn:   Churn{DurationBuckets: make(map[float64]uint64, len(sessionDurationBuckets))},
+			clients: clients,
+		}
+		for _, bucket := range sessionDurationBuckets {
+			churn.DurationBuckets[bucket] = 0
+		}
+		s.servers[server] = churn
+		return churn.snapshot()
+	}
+	connected, disconnected := openvpn.DiffSessions(churn.clients, clients)
+	reconnectedAt := make(map[string]time.Time, len(connected))
+	for _, client := range connected {
+		reconnectedAt[client.SessionKey()] = client.ConnectedSince
+	}
+	for _, client := range disconnected {
+		end, ok := reconnectedAt[client.SessionKey()]
+		if !ok {
+			end = updatedAt
+		}
+		churn.disconnect(end.Sub(client.ConnectedSince))
+	}
+	churn.Connects += float64(len(connected))
+	churn.clients = clients
+	return churn.snapshot()
+}
+
+func (c *serverChurn) disconnect(duration time.Duration) {
+	seconds := duration.Seconds()
+	if seconds < 0 {
+		seconds = 0
+	}
+	c.Disconnects++
+	c.DurationCount++
+	c.DurationSum += seconds
+	for _, bucket := range sessionDurationBuckets {
+		if seconds <= bucket {
+			c.DurationBuckets[bucket]++
+		}
+	}
+}
+
+func (c *serverChurn) snapshot() Churn {
+	churn := c.Churn
+	churn.DurationBuckets = make(map[float64]uint64, len(c.DurationBuckets))
+	for bucket, count := range c.DurationBuckets {
+		churn.DurationBuckets[bucket] = count
+	}
+	return churn
+}
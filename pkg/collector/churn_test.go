@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+var sessionChurnTestCases = []struct {
+	scenarioName        string
+	snapshots           [][]openvpn.Client
+	expectedConnects    float64
+	expectedDisconnects float64
+	expectedDurationSum float64
+}{
+	{
+		"initial sessions are not counted",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 0, 0), newTestClient("bar", "1194", 100, 0, 0)},
+			{newTestClient("foo", "1194", 100, 0, 0), newTestClient("bar", "1194", 100, 0, 0)},
+		},
+		0, 0, 0,
+	},
+	{
+		"connect",
+		[][]openvpn.Client{
+			{},
+			{newTestClient("foo", "1194", 150, 0, 0)},
+		},
+		1, 0, 0,
+	},
+	{
+		"disconnect",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 0, 0)},
+			{},
+		},
+		0, 1, 900,
+	},
+	{
+		"reconnect with new connected since",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 0, 0)},
+			{newTestClient("foo", "1194", 130, 0, 0)},
+		},
+		1, 1, 30,
+	},
+	{
+		"reconnect from another port",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 0, 0)},
+			{newTestClient("foo", "1195", 900, 0, 0)},
+		},
+		1, 1, 900,
+	},
+	{
+		"undef clients are ignored",
+		[][]openvpn.Client{
+			{},
+			{newTestClient("UNDEF", "1194", 100, 0, 0)},
+		},
+		0, 0, 0,
+	},
+}
+
+func TestSessionChurn(t *testing.T) {
+	for _, tt := range sessionChurnTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			churn := NewSessionChurn()
+			var result Churn
+			for i, snapshot := range tt.snapshots {
+				result = churn.Observe("test", snapshot, time.Unix(int64(1000*i), 0))
+			}
+			if result.Connects != tt.expectedConnects || result.Disconnects != tt.expectedDisconnects {
+				t.Errorf("expected %v connects and %v disconnects, got %v and %v", tt.expectedConnects, tt.expectedDisconnects, result.Connects, result.Disconnects)
+			}
+			if result.DurationCount != uint64(tt.expectedDisconnects) || result.DurationSum != tt.expectedDurationSum {
+				t.Errorf("expected %v session durations of %v seconds, got %v of %v", tt.expectedDisconnects, tt.expectedDurationSum, result.DurationCount, result.DurationSum)
+			}
+		})
+	}
+}
+
+func TestSessionChurnBuckets(t *testing.T) {
+	churn := NewSessionChurn()
+	churn.Observe("test", []openvpn.Client{
+		newTestClient("foo", "1194", 0, 0, 0),
+		newTestClient("bar", "1194", 0, 0, 0),
+	}, time.Unix(0, 0))
+	churn.Observe("test", []openvpn.Client{newTestClient("bar", "1194", 0, 0, 0)}, time.Unix(20, 0))
+	result := churn.Observe("test", nil, time.Unix(7200, 0))
+	expected := map[float64]uint64{10: 0, 30: 1, 3600: 1, 4 * 3600: 2, 7 * 24 * 3600: 2}
+	for bucket, count := range expected {
+		if result.DurationBuckets[bucket] != count {
+			t.Errorf("expected %d sessions in bucket %v, got %d", count, bucket, result.DurationBuckets[bucket])
+		}
+	}
+	if other := churn.Observe("other", nil, time.Unix(7200, 0)); other.Disconnects != 0 {
+		t.Errorf("servers should be tracked separately")
+	}
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// LogCollector collects the authentication and TLS failures and client disconnects found in
+// the log files of the servers
+type LogCollector struct {
+	logger               log.Logger
+	extraLabels          []string
+	OpenVPNServer        []OpenVPNServer
+	AuthFailures         *prometheus.Desc
+	TLSHandshakeFailures *prometheus.Desc
+	InactivityTimeouts   *prometheus.Desc
+	DisconnectReasons    *prometheus.Desc
+}
+
+// NewLogCollector returns a new LogCollector
+func NewLogCollector(logger log.Logger, openVPNServer []OpenVPNServer) *LogCollector {
+	extraLabels := extraLabelNames(openVPNServer)
+	labels := func(names ...string) []string {
+		return append(names, extraLabels...)
+	}
+	return &LogCollector{
+		logger:        logger,
+		extraLabels:   extraLabels,
+		OpenVPNServer: openVPNServer,
+
+		AuthFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "auth_failures_total"),
+			"Amount of failed client authentications by reason found in the log",
+			labels("server", "reason"),
+			nil,
+		),
+		TLSHandshakeFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tls_handshake_failures_total"),
+			"Amount of failed TLS handshakes found in the log",
+			labels("server"),
+			nil,
+		),
+		InactivityTimeouts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "inactivity_timeouts_total"),
+			"Amount of client inactivity timeouts found in the log",
+			labels("server"),
+			nil,
+		),
+		DisconnectReasons: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_disconnects_total"),
+			"Amount of client instances exiting or restarting by reason found in the log",
+			labels("server", "reason"),
+			nil,
+		),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics collected by this Collector.
+func (c *LogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.AuthFailures
+	ch <- c.TLSHandshakeFailures
+	ch <- c.InactivityTimeouts
+	ch <- c.DisconnectReasons
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (c *LogCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, ovpn := range c.OpenVPNServer {
+		if ovpn.LogTailer == nil {
+			continue
+		}
+		if err := ovpn.LogTailer.Err(); err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "error reading log file",
+				"name", ovpn.Name,
+				"err", err,
+			)
+		}
+		c.collect(ovpn, ovpn.LogTailer.Counters(), ch)
+	}
+}
+
+func (c *LogCollector) collect(ovpn OpenVPNServer, counters openvpn.LogCounters, ch chan<- prometheus.Metric) {
+	for reason, count := range counters.AuthFailures {
+		ch <- prometheus.MustNewConstMetric(
+			c.AuthFailures,
+			prometheus.CounterValue,
+			count,
+			c.labelValues(ovpn, ovpn.Name, reason)...,
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.TLSHandshakeFailures,
+		prometheus.CounterValue,
+		counters.TLSHandshakeFailures,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.InactivityTimeouts,
+		prometheus.CounterValue,
+		counters.InactivityTimeouts,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	for reason, count := range counters.ClientDisconnects {
+		ch <- prometheus.MustNewConstMetric(
+			c.DisconnectReasons,
+			prometheus.CounterValue,
+			count,
+			c.labelValues(ovpn, ovpn.Name, reason)...,
+		)
+	}
+}
+
+func (c *LogCollector) labelValues(ovpn OpenVPNServer, values ...string) []string {
+	return extraLabelValues(c.extraLabels, ovpn, values)
+}
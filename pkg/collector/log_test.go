@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+func TestLogCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "openvpn.log")
+
+	// a log file created after the tailer started is read from the start
+	tailer := openvpn.NewLogTailer(path, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tailer.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for tailer.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lines := "1.2.3.4:51234 TLS Auth Error: Auth Username/Password verification failed for peer\n" +
+		"1.2.3.4:51234 TLS Auth Error: Auth Username/Password verification failed for peer\n" +
+		"1.2.3.4:51234 TLS Error: TLS handshake failed\n" +
+		"test/1.2.3.4:51234 SIGTERM[soft,remote-exit] received, client-instance exiting\n"
+	if err := ioutil.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+	for tailer.Counters().ClientDisconnects["remote-exit"] == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	families := gatherFamilies(t, NewLogCollector(log.NewNopLogger(), []OpenVPNServer{
+		{Name: "test", LogTailer: tailer, Labels: map[string]string{"site": "a"}},
+		{Name: "nolog"},
+	}))
+	expected := map[string]float64{
+		"openvpn_auth_failures_total":          2,
+		"openvpn_tls_handshake_failures_total": 1,
+		"openvpn_inactivity_timeouts_total":    0,
+		"openvpn_client_disconnects_total":     1,
+	}
+	for name, value := range expected {
+		metrics := families[name].GetMetric()
+		if len(metrics) != 1 {
+			t.Errorf("expected a single %s metric, got %d", name, len(metrics))
+			continue
+		}
+		labels := labelMap(metrics[0].GetLabel())
+		if labels["server"] != "test" || labels["site"] != "a" {
+			t.Errorf("%s is not labeled correctly: %v", name, labels)
+		}
+		if metrics[0].GetCounter().GetValue() != value {
+			t.Errorf("expected %s to be %v, got %v", name, value, metrics[0].GetCounter().GetValue())
+		}
+	}
+	if reason := labelMap(families["openvpn_auth_failures_total"].GetMetric()[0].GetLabel())["reason"]; reason != "password" {
+		t.Errorf("expected reason password, got %s", reason)
+	}
+}
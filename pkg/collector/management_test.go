@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn/openvpntest"
+)
+
+// managementResponses returns the responses of a server with the clients of the version 3
+// example status
+func managementResponses(t *testing.T) map[string]string {
+	status, err := ioutil.ReadFile("../../example/version3.status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return openvpntest.Responses(string(status))
+}
+
+func newFakeManagement(t *testing.T, responses map[string]string) *openvpntest.Management {
+	f := openvpntest.NewManagement("tcp", "127.0.0.1:0", "", responses)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestManagementStats(t *testing.T) {
+	f := newFakeManagement(t, managementResponses(t))
+	unreachable, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable.Close()
+	families := gatherFamilies(t, NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{
+			{Name: "mgmt", ManagementAddress: f.Address(), ManagementTimeout: time.Second},
+			{Name: "unreachable", ManagementAddress: unreachable.Addr().String(), ManagementTimeout: time.Second},
+		},
+		true, false, false, nil, nil,
+	))
+	values := func(name string) map[string]float64 {
+		result := make(map[string]float64)
+		for _, metric := range families[name].GetMetric() {
+			value := metric.GetGauge().GetValue()
+			if metric.GetCounter() != nil {
+				value = metric.GetCounter().GetValue()
+			}
+			result[labelMap(metric.GetLabel())["server"]] = value
+		}
+		return result
+	}
+	expected := []struct {
+		name  string
+		value float64
+	}{
+		{"openvpn_server_bytes_in_total", 7731},
+		{"openvpn_server_bytes_out_total", 7612},
+		{"openvpn_server_clients", 2},
+		{"openvpn_connections", 2},
+	}
+	for _, tt := range expected {
+		if value := values(tt.name); len(value) != 1 || value["mgmt"] != tt.value {
+			t.Errorf("expected %s of %v, got %v", tt.name, tt.value, value)
+		}
+	}
+	versionInfo := families["openvpn_server_version_info"].GetMetric()
+	if len(versionInfo) != 1 {
+		t.Fatalf("expected the version info of the management interface, got %v", versionInfo)
+	}
+	labels := labelMap(versionInfo[0].GetLabel())
+	if labels["version"] != "2.4.4" || labels["arch"] != "x86_64-pc-linux-gnu" || labels["management_version"] != "1" {
+		t.Errorf("version info is not collected correctly: %v", labels)
+	}
+	if up := values("openvpn_up"); up["mgmt"] != 1 || up["unreachable"] != 0 {
+		t.Errorf("expected only the reachable management interface to be up, got %v", up)
+	}
+	for _, metric := range families["openvpn_collection_error"].GetMetric() {
+		labels := labelMap(metric.GetLabel())
+		if labels["server"] != "unreachable" || labels["reason"] != ReasonConnectionFailed {
+			t.Errorf("unexpected collection error: %v", labels)
+		}
+	}
+}
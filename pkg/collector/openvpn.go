@@ -1,26 +1,76 @@
 package collector
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 
 	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
 )
 
 // OpenVPNCollector collects metrics from openvpn status files
 type OpenVPNCollector struct {
-	logger                log.Logger
-	collectClientMetrics  bool
-	OpenVPNServer         []OpenVPNServer
-	LastUpdated           *prometheus.Desc
-	ConnectedClients      *prometheus.Desc
-	BytesReceived         *prometheus.Desc
-	BytesSent             *prometheus.Desc
-	ConnectedSince        *prometheus.Desc
-	MaxBcastMcastQueueLen *prometheus.Desc
-	ServerInfo            *prometheus.Desc
-	CollectionError       *prometheus.CounterVec
+	logger                    log.Logger
+	collectClientMetrics      bool
+	collectClientInfo         bool
+	collectProtocols          bool
+	clientTotals              *ClientTotals
+	sessionChurn              *SessionChurn
+	splitSessions             bool
+	extraLabels               []string
+	OpenVPNServer             []OpenVPNServer
+	Up                        *prometheus.Desc
+	StatusAge                 *prometheus.Desc
+	StatusFallback            *prometheus.Desc
+	StatusReads               *prometheus.Desc
+	LastUpdated               *prometheus.Desc
+	ConnectedClients          *prometheus.Desc
+	ConnectionsByProtocol     *prometheus.Desc
+	BytesReceived             *prometheus.Desc
+	BytesSent                 *prometheus.Desc
+	BytesReceivedTotal        *prometheus.Desc
+	BytesSentTotal            *prometheus.Desc
+	ConnectedSince            *prometheus.Desc
+	ClientSessions            *prometheus.Desc
+	ClientConnects            *prometheus.Desc
+	ClientDisconnects         *prometheus.Desc
+	SessionDuration           *prometheus.Desc
+	Routes                    *prometheus.Desc
+	ClientLastRef             *prometheus.Desc
+	ClientInfo                *prometheus.Desc
+	MaxBcastMcastQueueLen     *prometheus.Desc
+	PoolSize                  *prometheus.Desc
+	MaxClients                *prometheus.Desc
+	PoolPersistedLeases       *prometheus.Desc
+	PoolUtilization           *prometheus.Desc
+	ServerInfo                *prometheus.Desc
+	ServerVersionInfo         *prometheus.Desc
+	ServerClients             *prometheus.Desc
+	ServerBytesIn             *prometheus.Desc
+	ServerBytesOut            *prometheus.Desc
+	DisconnectedSessions      *prometheus.Desc
+	DisconnectedBytesReceived *prometheus.Desc
+	DisconnectedBytesSent     *prometheus.Desc
+	DisconnectedDuration      *prometheus.Desc
+	TunTapReadBytes           *prometheus.Desc
+	TunTapWriteBytes          *prometheus.Desc
+	TCPUDPReadBytes           *prometheus.Desc
+	TCPUDPWriteBytes          *prometheus.Desc
+	AuthReadBytes             *prometheus.Desc
+	PreCompressBytes          *prometheus.Desc
+	PostCompressBytes         *prometheus.Desc
+	PreDecompressBytes        *prometheus.Desc
+	PostDecompressBytes       *prometheus.Desc
+	CollectionError           *prometheus.Desc
 }
 
 // OpenVPNServer contains information of which servers will be scraped
@@ -28,79 +78,563 @@ type OpenVPNServer struct {
 	Name       string
 	StatusFile string
 	ParseError float64
+	// ManagementAddress takes precedence over StatusFile if set
+	ManagementAddress  string
+	ManagementPassword string
+	ManagementTimeout  time.Duration
+	// Tracker takes precedence over ManagementAddress and StatusFile if set
+	Tracker *openvpn.ClientTracker
+	// DuplicatePolicy defines how sessions sharing a common name are exported
+	DuplicatePolicy string
+	// DisableClientMetrics disables the per client metrics of the server
+	DisableClientMetrics bool
+	// Labels are static labels added to all metrics of the server
+	Labels map[string]string
+	// StaleAfter reports an error if the status has not been updated for the duration
+	StaleAfter time.Duration
+	// ConfigFile is the openvpn config file of the server to export the pool capacity from
+	ConfigFile string
+	// LogTailer follows the log file of the server to count failures and disconnects
+	LogTailer *openvpn.LogTailer
+	// StatusCache caches the parsed StatusFile until it changes if set
+	StatusCache *StatusCache
+	// StatusVersion is the expected status-version of StatusFile, any format is accepted if 0
+	StatusVersion int
+	// ConfigCache caches the parsed ConfigFile and its ifconfig-pool-persist file if set
+	ConfigCache *ConfigCache
+	// State keeps the collection errors and the last complete status, a new state is used
+	// if not set
+	State *ServerState
+}
+
+const (
+	// DuplicatePolicyDrop exports only the first session of a common name
+	DuplicatePolicyDrop = "drop"
+	// DuplicatePolicySum exports the sum of all sessions of a common name
+	DuplicatePolicySum = "sum"
+	// DuplicatePolicySplit exports all sessions with an additional session label
+	DuplicatePolicySplit = "split"
+)
+
+// DuplicatePolicies contains all supported duplicate common name policies
+var DuplicatePolicies = []string{DuplicatePolicyDrop, DuplicatePolicySum, DuplicatePolicySplit}
+
+// IsDuplicatePolicy reports whether policy is a supported duplicate common name policy
+func IsDuplicatePolicy(policy string) bool {
+	return contains(DuplicatePolicies, policy)
+}
+
+// reservedLabels contains the label names of the exported metrics, which cannot be used as
+// static extra labels
+var reservedLabels = []string{
+	"server", "common_name", "session", "protocol", "version", "arch", "additional_info",
+	"management_version", "virtual_address", "virtual_ipv6_address", "username", "client_id",
+	"peer_id", "platform", "gui_version", "ssl", "type", "file", "subject", "serial", "issuer",
+	"reason", "result",
+	// le and quantile are added to histogram and summary buckets
+	"le", "quantile",
+}
+
+// ValidateLabels reports an error if the static extra labels are invalid or conflict with the
+// labels of the exported metrics
+func ValidateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if contains(reservedLabels, name) {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+	}
+	return nil
+}
+
+func (s OpenVPNServer) status() (*openvpn.Status, error) {
+	if s.Tracker != nil {
+		return s.Tracker.Status()
+	}
+	if s.ManagementAddress != "" {
+		return openvpn.ParseManagement(s.ManagementAddress, s.ManagementPassword, s.ManagementTimeout)
+	}
+	var status *openvpn.Status
+	var err error
+	if s.StatusCache != nil {
+		status, err = s.StatusCache.Status(s.Name, s.StatusFile)
+	} else {
+		status, err = parseStatusFile(s.StatusFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the statistics of point-to-point and client mode instances have no status-version
+	if s.StatusVersion != 0 && status.Version != 0 && status.Version != s.StatusVersion {
+		return nil, fmt.Errorf("%w: status file has status-version %d instead of %d", openvpn.ErrUnknownFormat, status.Version, s.StatusVersion)
+	}
+	return status, nil
+}
+
+// openVPNConfig returns the parsed ConfigFile, through the cache if set
+func (s OpenVPNServer) openVPNConfig() (*openvpn.Config, error) {
+	if s.ConfigCache != nil {
+		return s.ConfigCache.Config(s.ConfigFile)
+	}
+	return openvpn.ParseConfigFile(s.ConfigFile)
+}
+
+// persistedLeases returns the amount of leases of the ifconfig-pool-persist file, through
+// the cache if set
+func (s OpenVPNServer) persistedLeases(path string) (int, error) {
+	if s.ConfigCache != nil {
+		return s.ConfigCache.PersistedLeases(path)
+	}
+	return openvpn.CountPersistedLeases(path)
+}
+
+// cachesStatus reports whether the status file of the server is read through the cache
+func (s OpenVPNServer) cachesStatus() bool {
+	return s.StatusCache != nil && s.Tracker == nil && s.ManagementAddress == ""
+}
+
+const (
+	// truncatedBackoff is the initial backoff before a truncated status file is read again,
+	// openvpn rewrites the file in place so it is usually complete after a short backoff
+	truncatedBackoff = 10 * time.Millisecond
+	// truncatedMaxBackoff caps the total backoff per status file, as the retries delay the
+	// scrape of all servers
+	truncatedMaxBackoff = 100 * time.Millisecond
+)
+
+// parseStatusFile parses the status file and retries if it was caught mid-write
+func parseStatusFile(path string) (*openvpn.Status, error) {
+	return retryTruncated(func() (*openvpn.Status, error) {
+		return openvpn.ParseFile(path)
+	}, time.Sleep)
+}
+
+// retryTruncated calls read until the status is not truncated, with an exponential backoff
+// until the total backoff reaches truncatedMaxBackoff
+func retryTruncated(read func() (*openvpn.Status, error), sleep func(time.Duration)) (*openvpn.Status, error) {
+	backoff, total := truncatedBackoff, time.Duration(0)
+	for {
+		status, err := read()
+		if !errors.Is(err, openvpn.ErrTruncated) || total >= truncatedMaxBackoff {
+			return status, err
+		}
+		if backoff > truncatedMaxBackoff-total {
+			backoff = truncatedMaxBackoff - total
+		}
+		sleep(backoff)
+		total += backoff
+		backoff *= 2
+	}
+}
+
+// Status returns the status of the server and reports an error if it is stale
+func (s OpenVPNServer) Status() (*openvpn.Status, error) {
+	status, err := s.status()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkStale(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Reasons of the collection errors
+const (
+	ReasonFileNotFound     = "file_not_found"
+	ReasonPermissionDenied = "permission_denied"
+	ReasonUnknownFormat    = "unknown_format"
+	ReasonTruncated        = "truncated"
+	ReasonStale            = "stale"
+	ReasonConnectionFailed = "connection_failed"
+	ReasonOther            = "other"
+)
+
+var errStale = errors.New("status is stale")
+
+// errorReason classifies an error of reading the status of a server
+func errorReason(err error) string {
+	var opErr *net.OpError
+	switch {
+	// management interfaces which cannot be reached, including missing unix sockets
+	case errors.As(err, &opErr):
+		return ReasonConnectionFailed
+	case errors.Is(err, os.ErrNotExist):
+		return ReasonFileNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ReasonPermissionDenied
+	case errors.Is(err, openvpn.ErrUnknownFormat):
+		return ReasonUnknownFormat
+	case errors.Is(err, openvpn.ErrTruncated):
+		return ReasonTruncated
+	case errors.Is(err, errStale):
+		return ReasonStale
+	default:
+		return ReasonOther
+	}
+}
+
+// checkStale reports an error if the status has not been updated for StaleAfter
+func (s OpenVPNServer) checkStale(status *openvpn.Status) error {
+	if s.StaleAfter > 0 && time.Since(status.UpdatedAt) > s.StaleAfter {
+		return fmt.Errorf("%w, last updated at %s", errStale, status.UpdatedAt.Format(time.RFC3339))
+	}
+	return nil
 }
 
 // NewOpenVPNCollector returns a new OpenVPNCollector
-func NewOpenVPNCollector(logger log.Logger, openVPNServer []OpenVPNServer, collectClientMetrics bool) *OpenVPNCollector {
+func NewOpenVPNCollector(logger log.Logger, openVPNServer []OpenVPNServer, collectClientMetrics bool, collectClientInfo bool, collectProtocols bool, clientTotals *ClientTotals, sessionChurn *SessionChurn) *OpenVPNCollector {
+	splitSessions := false
+	for _, server := range openVPNServer {
+		if server.DuplicatePolicy == DuplicatePolicySplit {
+			splitSessions = true
+		}
+	}
+	// servers without a state get their own, the state of the caller's servers is not changed
+	servers := make([]OpenVPNServer, len(openVPNServer))
+	for i, server := range openVPNServer {
+		if server.State == nil {
+			server.State = NewServerState()
+		}
+		servers[i] = server
+	}
+	extraLabels := extraLabelNames(openVPNServer)
+	labels := func(names ...string) []string {
+		return append(names, extraLabels...)
+	}
+	clientLabels := []string{"server", "common_name"}
+	if splitSessions {
+		clientLabels = append(clientLabels, "session")
+	}
 	return &OpenVPNCollector{
 		logger:               logger,
-		OpenVPNServer:        openVPNServer,
+		OpenVPNServer:        servers,
 		collectClientMetrics: collectClientMetrics,
+		collectClientInfo:    collectClientInfo,
+		collectProtocols:     collectProtocols,
+		clientTotals:         clientTotals,
+		sessionChurn:         sessionChurn,
+		splitSessions:        splitSessions,
+		extraLabels:          extraLabels,
 
+		Up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Whether the status of the server could be read and is not stale",
+			labels("server"),
+			nil,
+		),
+		StatusAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "status_age_seconds"),
+			"Seconds since the status of the server was last updated",
+			labels("server"),
+			nil,
+		),
+		StatusFallback: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "status_fallback"),
+			"Whether the last complete status is exported as the status of the server was truncated",
+			labels("server"),
+			nil,
+		),
+		StatusReads: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "status_reads_total"),
+			"Reads of the status file by result, either answered from the cache or parsed as the file changed",
+			labels("server", "result"),
+			nil,
+		),
 		LastUpdated: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "last_updated"),
 			"Unix timestamp when the last time the status was updated",
-			[]string{"server"},
+			labels("server"),
 			nil,
 		),
 		ConnectedClients: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "connections"),
 			"Amount of currently connected clients",
-			[]string{"server"},
+			labels("server"),
+			nil,
+		),
+		ConnectionsByProtocol: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connections_by_protocol"),
+			"Amount of currently connected clients by transport protocol",
+			labels("server", "protocol"),
 			nil,
 		),
 		MaxBcastMcastQueueLen: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "max_bcast_mcast_queue_len"),
 			"MaxBcastMcastQueueLen of the server",
-			[]string{"server"},
+			labels("server"),
+			nil,
+		),
+		PoolSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pool_size"),
+			"Amount of clients the ifconfig pool of the server can hold",
+			labels("server"),
+			nil,
+		),
+		MaxClients: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "max_clients"),
+			"Maximum amount of concurrently connected clients (max-clients)",
+			labels("server"),
+			nil,
+		),
+		PoolPersistedLeases: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pool_persisted_leases"),
+			"Amount of addresses persisted in the ifconfig-pool-persist file",
+			labels("server"),
+			nil,
+		),
+		PoolUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pool_utilization_ratio"),
+			"Ratio of connected clients to the capacity of the server, the smaller of pool size and max-clients",
+			labels("server"),
 			nil,
 		),
 		BytesReceived: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "bytes_received"),
 			"Amount of data received via the connection",
-			[]string{"server", "common_name"},
+			labels(clientLabels...),
 			nil,
 		),
 		BytesSent: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "bytes_sent"),
 			"Amount of data sent via the connection",
-			[]string{"server", "common_name"},
+			labels(clientLabels...),
+			nil,
+		),
+		BytesReceivedTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "bytes_received_total"),
+			"Amount of data received via all sessions of the common name",
+			labels("server", "common_name"),
+			nil,
+		),
+		BytesSentTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "bytes_sent_total"),
+			"Amount of data sent via all sessions of the common name",
+			labels("server", "common_name"),
+			nil,
+		),
+		ClientSessions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_sessions"),
+			"Amount of currently connected sessions of the common name",
+			labels("server", "common_name"),
 			nil,
 		),
 		ConnectedSince: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "connected_since"),
 			"Unixtimestamp when the connection was established",
-			[]string{"server", "common_name"},
+			labels(clientLabels...),
+			nil,
+		),
+		Routes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "routes"),
+			"Amount of entries in the routing table",
+			labels("server"),
+			nil,
+		),
+		ClientLastRef: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_last_ref"),
+			"Unix timestamp when the last packet was routed to or from the client",
+			labels("server", "common_name"),
+			nil,
+		),
+		ClientInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_info"),
+			"A metric with a constant '1' value labeled by client connection information",
+			labels(append(clientLabels, "virtual_address", "virtual_ipv6_address", "username", "client_id", "peer_id")...),
 			nil,
 		),
 		ServerInfo: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "server_info"),
 			"A metric with a constant '1' value labeled by version information",
-			[]string{"server", "version", "arch"},
+			labels("server", "version", "arch"),
 			nil,
 		),
-		CollectionError: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: prometheus.BuildFQName(namespace, "", "collection_error"),
-				Help: "Error occurred during collection",
-			},
-			[]string{"server"},
+		ServerVersionInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "server_version_info"),
+			"A metric with a constant '1' value labeled by version information reported by the management interface",
+			labels("server", "version", "arch", "additional_info", "management_version"),
+			nil,
+		),
+		ServerClients: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "server_clients"),
+			"Amount of connected clients reported by the management interface",
+			labels("server"),
+			nil,
+		),
+		ServerBytesIn: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "server_bytes_in_total"),
+			"Amount of data received by the server process reported by the management interface",
+			labels("server"),
+			nil,
+		),
+		ServerBytesOut: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "server_bytes_out_total"),
+			"Amount of data sent by the server process reported by the management interface",
+			labels("server"),
+			nil,
+		),
+		ClientConnects: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_connects_total"),
+			"Amount of sessions which appeared between consecutive status snapshots",
+			labels("server"),
+			nil,
+		),
+		ClientDisconnects: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_session_disconnects_total"),
+			"Amount of sessions which disappeared between consecutive status snapshots",
+			labels("server"),
+			nil,
+		),
+		SessionDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_session_duration_seconds"),
+			"Duration of sessions which disappeared between consecutive status snapshots",
+			labels("server"),
+			nil,
+		),
+		DisconnectedSessions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "disconnected_sessions_total"),
+			"Amount of sessions which were disconnected",
+			labels("server", "common_name"),
+			nil,
+		),
+		DisconnectedBytesReceived: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "disconnected_bytes_received_total"),
+			"Amount of data received via sessions which were disconnected",
+			labels("server", "common_name"),
+			nil,
+		),
+		DisconnectedBytesSent: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "disconnected_bytes_sent_total"),
+			"Amount of data sent via sessions which were disconnected",
+			labels("server", "common_name"),
+			nil,
+		),
+		DisconnectedDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "disconnected_duration_seconds_total"),
+			"Duration of sessions which were disconnected",
+			labels("server", "common_name"),
+			nil,
+		),
+		TunTapReadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tun_tap_read_bytes_total"),
+			"Amount of bytes read from the TUN/TAP device",
+			labels("server"),
+			nil,
+		),
+		TunTapWriteBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tun_tap_write_bytes_total"),
+			"Amount of bytes written to the TUN/TAP device",
+			labels("server"),
+			nil,
+		),
+		TCPUDPReadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tcp_udp_read_bytes_total"),
+			"Amount of bytes read from the TCP/UDP socket",
+			labels("server"),
+			nil,
+		),
+		TCPUDPWriteBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tcp_udp_write_bytes_total"),
+			"Amount of bytes written to the TCP/UDP socket",
+			labels("server"),
+			nil,
+		),
+		AuthReadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "auth_read_bytes_total"),
+			"Amount of authenticated bytes read from the TCP/UDP socket",
+			labels("server"),
+			nil,
+		),
+		PreCompressBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pre_compress_bytes_total"),
+			"Amount of bytes before compression",
+			labels("server"),
+			nil,
+		),
+		PostCompressBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "post_compress_bytes_total"),
+			"Amount of bytes after compression",
+			labels("server"),
+			nil,
+		),
+		PreDecompressBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pre_decompress_bytes_total"),
+			"Amount of bytes before decompression",
+			labels("server"),
+			nil,
+		),
+		PostDecompressBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "post_decompress_bytes_total"),
+			"Amount of bytes after decompression",
+			labels("server"),
+			nil,
+		),
+		CollectionError: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "collection_error"),
+			"Error occurred during collection",
+			labels("server", "reason"),
+			nil,
 		),
 	}
 }
 
 // Describe sends the super-set of all possible descriptors of metrics collected by this Collector.
 func (c *OpenVPNCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.Up
+	ch <- c.StatusAge
+	ch <- c.StatusFallback
+	ch <- c.StatusReads
 	ch <- c.LastUpdated
 	ch <- c.ConnectedClients
+	if c.collectProtocols {
+		ch <- c.ConnectionsByProtocol
+	}
 	ch <- c.MaxBcastMcastQueueLen
+	ch <- c.PoolSize
+	ch <- c.MaxClients
+	ch <- c.PoolPersistedLeases
+	ch <- c.PoolUtilization
+	ch <- c.Routes
 	ch <- c.ServerInfo
+	ch <- c.ServerVersionInfo
+	ch <- c.ServerClients
+	ch <- c.ServerBytesIn
+	ch <- c.ServerBytesOut
+	ch <- c.TunTapReadBytes
+	ch <- c.TunTapWriteBytes
+	ch <- c.TCPUDPReadBytes
+	ch <- c.TCPUDPWriteBytes
+	ch <- c.AuthReadBytes
+	ch <- c.PreCompressBytes
+	ch <- c.PostCompressBytes
+	ch <- c.PreDecompressBytes
+	ch <- c.PostDecompressBytes
 	if c.collectClientMetrics {
 		ch <- c.BytesSent
 		ch <- c.BytesReceived
 		ch <- c.ConnectedSince
+		ch <- c.ClientSessions
+		ch <- c.ClientLastRef
+		ch <- c.DisconnectedSessions
+		ch <- c.DisconnectedBytesReceived
+		ch <- c.DisconnectedBytesSent
+		ch <- c.DisconnectedDuration
+		if c.collectClientInfo {
+			ch <- c.ClientInfo
+		}
+	}
+	if c.clientTotals != nil {
+		ch <- c.BytesReceivedTotal
+		ch <- c.BytesSentTotal
 	}
-	c.CollectionError.Describe(ch)
+	if c.sessionChurn != nil {
+		ch <- c.ClientConnects
+		ch <- c.ClientDisconnects
+		ch <- c.SessionDuration
+	}
+	ch <- c.CollectionError
 }
 
 // Collect is called by the Prometheus registry when collecting metrics.
@@ -108,63 +642,125 @@ func (c *OpenVPNCollector) Collect(ch chan<- prometheus.Metric) {
 	for _, ovpn := range c.OpenVPNServer {
 		c.collect(ovpn, ch)
 	}
+	for _, ovpn := range c.OpenVPNServer {
+		for reason, value := range ovpn.State.Errors() {
+			ch <- prometheus.MustNewConstMetric(
+				c.CollectionError,
+				prometheus.CounterValue,
+				value,
+				c.labelValues(ovpn, ovpn.Name, reason)...,
+			)
+		}
+	}
 }
 
 func (c *OpenVPNCollector) collect(ovpn OpenVPNServer, ch chan<- prometheus.Metric) {
 	level.Debug(c.logger).Log(
 		"statusFile", ovpn.StatusFile,
+		"managementAddress", ovpn.ManagementAddress,
 		"name", ovpn.Name,
 	)
-	status, err := openvpn.ParseFile(ovpn.StatusFile)
+	status, err := ovpn.status()
+	if ovpn.cachesStatus() {
+		stats := ovpn.StatusCache.Stats(ovpn.Name, ovpn.StatusFile)
+		for result, value := range map[string]float64{"cache_hit": stats.Hits, "parse": stats.Parses} {
+			ch <- prometheus.MustNewConstMetric(
+				c.StatusReads,
+				prometheus.CounterValue,
+				value,
+				c.labelValues(ovpn, ovpn.Name, result)...,
+			)
+		}
+	}
+	fallback := false
+	switch {
+	case err == nil:
+		ovpn.State.storeSnapshot(status)
+	case errors.Is(err, openvpn.ErrTruncated):
+		if snapshot := ovpn.State.Snapshot(); snapshot != nil {
+			c.collectionError(ovpn, err, "using last complete status")
+			status, err, fallback = snapshot, nil, true
+		}
+	}
+	// a stale server is reported as down, only its per client metrics are suppressed
+	stale := false
+	if err == nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.StatusAge,
+			prometheus.GaugeValue,
+			time.Since(status.UpdatedAt).Seconds(),
+			c.labelValues(ovpn, ovpn.Name)...,
+		)
+		if staleErr := ovpn.checkStale(status); staleErr != nil {
+			c.collectionError(ovpn, staleErr, "status is stale")
+			stale = true
+		}
+	}
 	if err != nil {
-		level.Warn(c.logger).Log(
-			"msg", "error parsing statusfile",
-			"err", err,
+		c.collectionError(ovpn, err, "error parsing status")
+		ch <- prometheus.MustNewConstMetric(
+			c.Up,
+			prometheus.GaugeValue,
+			0,
+			c.labelValues(ovpn, ovpn.Name)...,
 		)
-		c.CollectionError.WithLabelValues(ovpn.Name).Add(1)
-		c.CollectionError.Collect(ch)
+		return
+	}
+	up := 1.0
+	if stale {
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.Up,
+		prometheus.GaugeValue,
+		up,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	fallbackValue := 0.0
+	if fallback {
+		fallbackValue = 1
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.StatusFallback,
+		prometheus.GaugeValue,
+		fallbackValue,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+
+	if status.Statistics != nil {
+		c.collectStatistics(ovpn, status, ch)
 		return
 	}
 
+	collectClientMetrics := c.collectClientMetrics && !ovpn.DisableClientMetrics && !stale
 	connectedClients := 0
-	var clientCommonNames []string
+	connectionsByProtocol := make(map[string]int)
 	for _, client := range status.ClientList {
 		connectedClients++
+		connectionsByProtocol[protocolLabel(client.Protocol)]++
 		level.Debug(c.logger).Log(
 			"commonName", client.CommonName,
 			"connectedSince", client.ConnectedSince.Unix(),
 			"bytesReceived", client.BytesReceived,
 			"bytesSent", client.BytesSent,
 		)
-		if c.collectClientMetrics {
-			if client.CommonName == "UNDEF" {
-				continue
-			}
-			if contains(clientCommonNames, client.CommonName) {
-				level.Warn(c.logger).Log(
-					"msg", "duplicate client common name in statusfile - duplicate metric dropped",
-					"commonName", client.CommonName,
-				)
-				continue
-			}
-			clientCommonNames = append(clientCommonNames, client.CommonName)
-			ch <- prometheus.MustNewConstMetric(
-				c.BytesReceived,
-				prometheus.GaugeValue,
-				client.BytesReceived,
-				ovpn.Name, client.CommonName,
-			)
-			ch <- prometheus.MustNewConstMetric(
-				c.BytesSent,
-				prometheus.GaugeValue,
-				client.BytesSent,
-				ovpn.Name, client.CommonName,
-			)
+	}
+	if collectClientMetrics {
+		c.collectClients(ovpn, status.ClientList, ch)
+	}
+	if c.clientTotals != nil && !stale {
+		c.collectClientTotals(ovpn, status, ch)
+	}
+	if c.sessionChurn != nil {
+		c.collectSessionChurn(ovpn, status, ch)
+	}
+	if collectClientMetrics {
+		for commonName, lastRef := range lastRefByCommonName(status.Routes) {
 			ch <- prometheus.MustNewConstMetric(
-				c.ConnectedSince,
+				c.ClientLastRef,
 				prometheus.GaugeValue,
-				float64(client.ConnectedSince.Unix()),
-				ovpn.Name, client.CommonName,
+				float64(lastRef.Unix()),
+				c.labelValues(ovpn, ovpn.Name, commonName)...,
 			)
 		}
 	}
@@ -177,30 +773,464 @@ func (c *OpenVPNCollector) collect(ovpn OpenVPNServer, ch chan<- prometheus.Metr
 		c.ConnectedClients,
 		prometheus.GaugeValue,
 		float64(connectedClients),
-		ovpn.Name,
+		c.labelValues(ovpn, ovpn.Name)...,
 	)
+	if c.collectProtocols {
+		for protocol, connections := range connectionsByProtocol {
+			ch <- prometheus.MustNewConstMetric(
+				c.ConnectionsByProtocol,
+				prometheus.GaugeValue,
+				float64(connections),
+				c.labelValues(ovpn, ovpn.Name, protocol)...,
+			)
+		}
+	}
 	ch <- prometheus.MustNewConstMetric(
 		c.LastUpdated,
 		prometheus.GaugeValue,
 		float64(status.UpdatedAt.Unix()),
-		ovpn.Name,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.Routes,
+		prometheus.GaugeValue,
+		float64(len(status.Routes)),
+		c.labelValues(ovpn, ovpn.Name)...,
 	)
 	ch <- prometheus.MustNewConstMetric(
 		c.MaxBcastMcastQueueLen,
 		prometheus.GaugeValue,
 		float64(status.GlobalStats.MaxBcastMcastQueueLen),
-		ovpn.Name,
+		c.labelValues(ovpn, ovpn.Name)...,
 	)
 	ch <- prometheus.MustNewConstMetric(
 		c.ServerInfo,
 		prometheus.GaugeValue,
 		1.0,
-		ovpn.Name,
-		status.ServerInfo.Version,
-		status.ServerInfo.Arch,
+		c.labelValues(
+			ovpn,
+			ovpn.Name,
+			status.ServerInfo.Version,
+			status.ServerInfo.Arch,
+		)...,
+	)
+	if ovpn.ConfigFile != "" {
+		c.collectPool(ovpn, connectedClients, ch)
+	}
+	if status.LoadStats != nil {
+		c.collectManagementStats(ovpn, status, ch)
+	}
+	if ovpn.Tracker != nil && collectClientMetrics {
+		c.collectDisconnectTotals(ovpn, ovpn.Tracker.DisconnectTotals(), ch)
+	}
+}
+
+// collectionError logs the error and counts it by reason
+func (c *OpenVPNCollector) collectionError(ovpn OpenVPNServer, err error, msg string) {
+	reason := errorReason(err)
+	level.Warn(c.logger).Log(
+		"msg", msg,
+		"name", ovpn.Name,
+		"reason", reason,
+		"err", err,
+	)
+	ovpn.State.addError(reason)
+}
+
+func (c *OpenVPNCollector) collectClients(ovpn OpenVPNServer, clients []openvpn.Client, ch chan<- prometheus.Metric) {
+	for commonName, sessions := range sessionsByCommonName(clients) {
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientSessions,
+			prometheus.GaugeValue,
+			float64(sessions),
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+	}
+	usedSessionLabels := make(map[string]bool)
+	for _, client := range c.applyDuplicatePolicy(ovpn, clients) {
+		clientLabels := []string{ovpn.Name, client.CommonName}
+		if c.splitSessions {
+			session := ""
+			if ovpn.DuplicatePolicy == DuplicatePolicySplit {
+				session = uniqueSessionLabel(client, usedSessionLabels)
+			}
+			clientLabels = append(clientLabels, session)
+		}
+		labels := c.labelValues(ovpn, clientLabels...)
+		ch <- prometheus.MustNewConstMetric(
+			c.BytesReceived,
+			prometheus.GaugeValue,
+			client.BytesReceived,
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.BytesSent,
+			prometheus.GaugeValue,
+			client.BytesSent,
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ConnectedSince,
+			prometheus.GaugeValue,
+			float64(client.ConnectedSince.Unix()),
+			labels...,
+		)
+		if c.collectClientInfo {
+			ch <- prometheus.MustNewConstMetric(
+				c.ClientInfo,
+				prometheus.GaugeValue,
+				1.0,
+				c.labelValues(
+					ovpn,
+					append(
+						clientLabels,
+						client.VirtualAddress,
+						client.VirtualIPv6Address,
+						client.Username,
+						client.ClientID,
+						client.PeerID,
+					)...,
+				)...,
+			)
+		}
+	}
+}
+
+// applyDuplicatePolicy returns the clients to export metrics for according to the
+// duplicate common name policy of the server. UNDEF clients are always skipped.
+func (c *OpenVPNCollector) applyDuplicatePolicy(ovpn OpenVPNServer, clients []openvpn.Client) []openvpn.Client {
+	var result []openvpn.Client
+	index := make(map[string]int)
+	for _, client := range clients {
+		if client.CommonName == "UNDEF" {
+			continue
+		}
+		if ovpn.DuplicatePolicy == DuplicatePolicySplit {
+			result = append(result, client)
+			continue
+		}
+		i, duplicate := index[client.CommonName]
+		if !duplicate {
+			index[client.CommonName] = len(result)
+			result = append(result, client)
+			continue
+		}
+		if ovpn.DuplicatePolicy == DuplicatePolicySum {
+			result[i].BytesReceived += client.BytesReceived
+			result[i].BytesSent += client.BytesSent
+			if client.ConnectedSince.Before(result[i].ConnectedSince) {
+				result[i].ConnectedSince = client.ConnectedSince
+			}
+			continue
+		}
+		level.Warn(c.logger).Log(
+			"msg", "duplicate client common name in statusfile - duplicate metric dropped",
+			"commonName", client.CommonName,
+		)
+	}
+	return result
+}
+
+func (c *OpenVPNCollector) collectClientTotals(ovpn OpenVPNServer, status *openvpn.Status, ch chan<- prometheus.Metric) {
+	totals, err := c.clientTotals.Observe(ovpn.Name, status.ClientList)
+	if err != nil {
+		level.Warn(c.logger).Log(
+			"msg", "error saving client totals state",
+			"err", err,
+		)
+	}
+	for commonName, traffic := range totals {
+		ch <- prometheus.MustNewConstMetric(
+			c.BytesReceivedTotal,
+			prometheus.CounterValue,
+			traffic.BytesReceived,
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.BytesSentTotal,
+			prometheus.CounterValue,
+			traffic.BytesSent,
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+	}
+}
+
+func (c *OpenVPNCollector) collectSessionChurn(ovpn OpenVPNServer, status *openvpn.Status, ch chan<- prometheus.Metric) {
+	updatedAt := status.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+	churn := c.sessionChurn.Observe(ovpn.Name, status.ClientList, updatedAt)
+	ch <- prometheus.MustNewConstMetric(
+		c.ClientConnects,
+		prometheus.CounterValue,
+		churn.Connects,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.ClientDisconnects,
+		prometheus.CounterValue,
+		churn.Disconnects,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	ch <- prometheus.MustNewConstHistogram(
+		c.SessionDuration,
+		churn.DurationCount,
+		churn.DurationSum,
+		churn.DurationBuckets,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+}
+
+func (c *OpenVPNCollector) collectDisconnectTotals(ovpn OpenVPNServer, disconnectTotals map[string]openvpn.DisconnectTotals, ch chan<- prometheus.Metric) {
+	for commonName, totals := range disconnectTotals {
+		if commonName == "UNDEF" || commonName == "" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.DisconnectedSessions,
+			prometheus.CounterValue,
+			totals.Sessions,
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DisconnectedBytesReceived,
+			prometheus.CounterValue,
+			totals.BytesReceived,
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DisconnectedBytesSent,
+			prometheus.CounterValue,
+			totals.BytesSent,
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DisconnectedDuration,
+			prometheus.CounterValue,
+			totals.Duration,
+			c.labelValues(ovpn, ovpn.Name, commonName)...,
+		)
+	}
+}
+
+func (c *OpenVPNCollector) collectPool(ovpn OpenVPNServer, connectedClients int, ch chan<- prometheus.Metric) {
+	config, err := ovpn.openVPNConfig()
+	if err != nil {
+		level.Warn(c.logger).Log(
+			"msg", "error parsing openvpn config",
+			"name", ovpn.Name,
+			"configFile", ovpn.ConfigFile,
+			"err", err,
+		)
+		return
+	}
+	poolSize := config.PoolSize()
+	level.Debug(c.logger).Log(
+		"poolSize", poolSize,
+		"maxClients", config.MaxClients,
+		"connectedClients", connectedClients,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.MaxClients,
+		prometheus.GaugeValue,
+		float64(config.MaxClients),
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	capacity := config.MaxClients
+	if poolSize > 0 {
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolSize,
+			prometheus.GaugeValue,
+			float64(poolSize),
+			c.labelValues(ovpn, ovpn.Name)...,
+		)
+		if poolSize < capacity {
+			capacity = poolSize
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.PoolUtilization,
+		prometheus.GaugeValue,
+		float64(connectedClients)/float64(capacity),
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	if config.IfconfigPoolPersist == "" {
+		return
+	}
+	leases, err := ovpn.persistedLeases(config.IfconfigPoolPersist)
+	if err != nil {
+		level.Warn(c.logger).Log(
+			"msg", "error reading ifconfig-pool-persist file",
+			"name", ovpn.Name,
+			"file", config.IfconfigPoolPersist,
+			"err", err,
+		)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.PoolPersistedLeases,
+		prometheus.GaugeValue,
+		float64(leases),
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+}
+
+func (c *OpenVPNCollector) collectManagementStats(ovpn OpenVPNServer, status *openvpn.Status, ch chan<- prometheus.Metric) {
+	level.Debug(c.logger).Log(
+		"managementVersion", status.ServerInfo.ManagementVersion,
+		"clients", status.LoadStats.Clients,
+		"bytesIn", status.LoadStats.BytesIn,
+		"bytesOut", status.LoadStats.BytesOut,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.ServerVersionInfo,
+		prometheus.GaugeValue,
+		1.0,
+		c.labelValues(
+			ovpn,
+			ovpn.Name,
+			status.ServerInfo.Version,
+			status.ServerInfo.Arch,
+			status.ServerInfo.AdditionalInfo,
+			status.ServerInfo.ManagementVersion,
+		)...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.ServerClients,
+		prometheus.GaugeValue,
+		float64(status.LoadStats.Clients),
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.ServerBytesIn,
+		prometheus.CounterValue,
+		status.LoadStats.BytesIn,
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.ServerBytesOut,
+		prometheus.CounterValue,
+		status.LoadStats.BytesOut,
+		c.labelValues(ovpn, ovpn.Name)...,
 	)
 }
 
+func (c *OpenVPNCollector) collectStatistics(ovpn OpenVPNServer, status *openvpn.Status, ch chan<- prometheus.Metric) {
+	level.Debug(c.logger).Log(
+		"updatedAt", status.UpdatedAt,
+		"tunTapReadBytes", status.Statistics.TunTapReadBytes,
+		"tunTapWriteBytes", status.Statistics.TunTapWriteBytes,
+		"tcpUDPReadBytes", status.Statistics.TCPUDPReadBytes,
+		"tcpUDPWriteBytes", status.Statistics.TCPUDPWriteBytes,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.LastUpdated,
+		prometheus.GaugeValue,
+		float64(status.UpdatedAt.Unix()),
+		c.labelValues(ovpn, ovpn.Name)...,
+	)
+	counters := map[*prometheus.Desc]float64{
+		c.TunTapReadBytes:     status.Statistics.TunTapReadBytes,
+		c.TunTapWriteBytes:    status.Statistics.TunTapWriteBytes,
+		c.TCPUDPReadBytes:     status.Statistics.TCPUDPReadBytes,
+		c.TCPUDPWriteBytes:    status.Statistics.TCPUDPWriteBytes,
+		c.AuthReadBytes:       status.Statistics.AuthReadBytes,
+		c.PreCompressBytes:    status.Statistics.PreCompressBytes,
+		c.PostCompressBytes:   status.Statistics.PostCompressBytes,
+		c.PreDecompressBytes:  status.Statistics.PreDecompressBytes,
+		c.PostDecompressBytes: status.Statistics.PostDecompressBytes,
+	}
+	for desc, value := range counters {
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			value,
+			c.labelValues(ovpn, ovpn.Name)...,
+		)
+	}
+}
+
+func (c *OpenVPNCollector) labelValues(ovpn OpenVPNServer, values ...string) []string {
+	return extraLabelValues(c.extraLabels, ovpn, values)
+}
+
+// extraLabelValues returns values followed by the values of the static extra labels of the server
+func extraLabelValues(extraLabels []string, ovpn OpenVPNServer, values []string) []string {
+	result := make([]string, 0, len(values)+len(extraLabels))
+	result = append(result, values...)
+	for _, name := range extraLabels {
+		result = append(result, ovpn.Labels[name])
+	}
+	return result
+}
+
+// extraLabelNames returns the sorted union of the static extra label names of all servers, as
+// all metrics of a name need the same label names
+func extraLabelNames(servers []OpenVPNServer) []string {
+	var names []string
+	for _, server := range servers {
+		for name := range server.Labels {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sessionsByCommonName returns the amount of sessions per common name
+func sessionsByCommonName(clients []openvpn.Client) map[string]int {
+	sessions := make(map[string]int)
+	for _, client := range clients {
+		if client.CommonName == "UNDEF" {
+			continue
+		}
+		sessions[client.CommonName]++
+	}
+	return sessions
+}
+
+// uniqueSessionLabel returns the client id or the real address of the client as session
+// discriminator, which is made unique within the common name if necessary
+func uniqueSessionLabel(client openvpn.Client, used map[string]bool) string {
+	session := client.ClientID
+	if session == "" {
+		session = client.RealAddress
+		if client.RealPort != "" {
+			session = net.JoinHostPort(client.RealAddress, client.RealPort)
+		}
+	}
+	label := session
+	for i := 2; used[client.CommonName+"|"+label]; i++ {
+		label = fmt.Sprintf("%s#%d", session, i)
+	}
+	used[client.CommonName+"|"+label] = true
+	return label
+}
+
+// lastRefByCommonName returns the most recent last ref of all routes per common name
+func lastRefByCommonName(routes []openvpn.Route) map[string]time.Time {
+	lastRefs := make(map[string]time.Time)
+	for _, route := range routes {
+		if route.CommonName == "UNDEF" {
+			continue
+		}
+		if lastRef, ok := lastRefs[route.CommonName]; !ok || route.LastRef.After(lastRef) {
+			lastRefs[route.CommonName] = route.LastRef
+		}
+	}
+	return lastRefs
+}
+
+func protocolLabel(protocol string) string {
+	if protocol == "" {
+		return "unknown"
+	}
+	return protocol
+}
+
 func contains(list []string, item string) bool {
 	for _, e := range list {
 		if e == item {
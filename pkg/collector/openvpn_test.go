@@ -1,6 +1,20 @@
 package collector
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
 
 var containsTestCases = []struct {
 	scenarioName string
@@ -21,3 +35,387 @@ func TestContainsFunction(t *testing.T) {
 		})
 	}
 }
+
+func TestLastRefByCommonName(t *testing.T) {
+	routes := []openvpn.Route{
+		{VirtualAddress: "10.0.0.1", CommonName: "foo", LastRef: time.Unix(100, 0)},
+		{VirtualAddress: "10.0.1.0/24", CommonName: "foo", LastRef: time.Unix(200, 0)},
+		{VirtualAddress: "10.0.0.2", CommonName: "bar", LastRef: time.Unix(50, 0)},
+		{VirtualAddress: "10.0.0.3", CommonName: "UNDEF", LastRef: time.Unix(300, 0)},
+	}
+	lastRefs := lastRefByCommonName(routes)
+	if len(lastRefs) != 2 {
+		t.Fatalf("Unexpected amount of common names: %d", len(lastRefs))
+	}
+	if !lastRefs["foo"].Equal(time.Unix(200, 0)) {
+		t.Errorf("Expected most recent last ref for foo")
+	}
+	if !lastRefs["bar"].Equal(time.Unix(50, 0)) {
+		t.Errorf("Unexpected last ref for bar")
+	}
+}
+
+var duplicateClients = []openvpn.Client{
+	{CommonName: "foo", RealAddress: "1.1.1.1", BytesReceived: 10, BytesSent: 20, ConnectedSince: time.Unix(200, 0)},
+	{CommonName: "bar", RealAddress: "2.2.2.2", BytesReceived: 1, BytesSent: 2, ConnectedSince: time.Unix(100, 0)},
+	{CommonName: "foo", RealAddress: "1.1.1.1", BytesReceived: 30, BytesSent: 40, ConnectedSince: time.Unix(100, 0)},
+	{CommonName: "UNDEF", RealAddress: "3.3.3.3", BytesReceived: 5, BytesSent: 5, ConnectedSince: time.Unix(100, 0)},
+}
+
+var duplicatePolicyTestCases = []struct {
+	policy          string
+	expectedClients int
+	expectedFoo     openvpn.Client
+}{
+	{DuplicatePolicyDrop, 2, duplicateClients[0]},
+	{"", 2, duplicateClients[0]},
+	{DuplicatePolicySum, 2, openvpn.Client{CommonName: "foo", RealAddress: "1.1.1.1", BytesReceived: 40, BytesSent: 60, ConnectedSince: time.Unix(100, 0)}},
+	{DuplicatePolicySplit, 3, duplicateClients[0]},
+}
+
+func TestApplyDuplicatePolicy(t *testing.T) {
+	for _, tt := range duplicatePolicyTestCases {
+		t.Run(tt.policy, func(t *testing.T) {
+			c := NewOpenVPNCollector(log.NewNopLogger(), nil, true, false, false, nil, nil)
+			clients := c.applyDuplicatePolicy(OpenVPNServer{DuplicatePolicy: tt.policy}, duplicateClients)
+			if len(clients) != tt.expectedClients {
+				t.Fatalf("Unexpected amount of clients: %d", len(clients))
+			}
+			foo := clients[0]
+			if foo.BytesReceived != tt.expectedFoo.BytesReceived || foo.BytesSent != tt.expectedFoo.BytesSent {
+				t.Errorf("Unexpected bytes: %+v", foo)
+			}
+			if !foo.ConnectedSince.Equal(tt.expectedFoo.ConnectedSince) {
+				t.Errorf("Unexpected connected since: %v", foo.ConnectedSince)
+			}
+		})
+	}
+}
+
+func TestSessionsByCommonName(t *testing.T) {
+	sessions := sessionsByCommonName(duplicateClients)
+	if len(sessions) != 2 || sessions["foo"] != 2 || sessions["bar"] != 1 {
+		t.Errorf("Unexpected sessions: %v", sessions)
+	}
+}
+
+func TestUniqueSessionLabel(t *testing.T) {
+	used := make(map[string]bool)
+	labels := []string{
+		uniqueSessionLabel(openvpn.Client{CommonName: "foo", ClientID: "7"}, used),
+		uniqueSessionLabel(openvpn.Client{CommonName: "foo", RealAddress: "1.1.1.1", RealPort: "1194"}, used),
+		uniqueSessionLabel(openvpn.Client{CommonName: "foo", RealAddress: "1.1.1.1"}, used),
+		uniqueSessionLabel(openvpn.Client{CommonName: "foo", RealAddress: "1.1.1.1"}, used),
+		uniqueSessionLabel(openvpn.Client{CommonName: "bar", RealAddress: "1.1.1.1"}, used),
+	}
+	expected := []string{"7", "1.1.1.1:1194", "1.1.1.1", "1.1.1.1#2", "1.1.1.1"}
+	for i := range expected {
+		if labels[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], labels[i])
+		}
+	}
+}
+
+func TestDuplicateCommonNamesCanBeGathered(t *testing.T) {
+	for _, policy := range DuplicatePolicies {
+		t.Run(policy, func(t *testing.T) {
+			r := prometheus.NewPedanticRegistry()
+			r.MustRegister(NewOpenVPNCollector(
+				log.NewNopLogger(),
+				[]OpenVPNServer{
+					{Name: "duplicate", StatusFile: "../../example/duplicate.status", DuplicatePolicy: policy},
+					{Name: "v2", StatusFile: "../../example/version2.status", Labels: map[string]string{"site": "fra1"}},
+					{Name: "v3", StatusFile: "../../example/version3.status", DisableClientMetrics: true},
+				},
+				true, true, false, nil, NewSessionChurn(),
+			))
+			if _, err := r.Gather(); err != nil {
+				t.Errorf("gathering failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtraLabelNames(t *testing.T) {
+	names := extraLabelNames([]OpenVPNServer{
+		{Labels: map[string]string{"site": "fra1", "tier": "production"}},
+		{Labels: map[string]string{"site": "ams1", "dc": "a"}},
+		{},
+	})
+	expected := []string{"dc", "site", "tier"}
+	if len(names) != len(expected) {
+		t.Fatalf("Unexpected label names: %v", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Unexpected label names: %v", names)
+		}
+	}
+	values := extraLabelValues(names, OpenVPNServer{Labels: map[string]string{"site": "fra1"}}, []string{"v1"})
+	if len(values) != 4 || values[0] != "v1" || values[1] != "" || values[2] != "fra1" {
+		t.Errorf("Unexpected label values: %v", values)
+	}
+}
+
+var validateLabelsTestCases = []struct {
+	scenarioName string
+	labels       map[string]string
+	valid        bool
+}{
+	{"valid", map[string]string{"site": "fra1"}, true},
+	{"empty", nil, true},
+	{"invalid", map[string]string{"data-center": "fra1"}, false},
+	{"internal", map[string]string{"__name__": "fra1"}, false},
+	{"reserved", map[string]string{"common_name": "fra1"}, false},
+	{"histogram bucket", map[string]string{"le": "fra1"}, false},
+	{"summary quantile", map[string]string{"quantile": "fra1"}, false},
+}
+
+func TestValidateLabels(t *testing.T) {
+	for _, tt := range validateLabelsTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			if err := ValidateLabels(tt.labels); (err == nil) != tt.valid {
+				t.Errorf("Unexpected result: %v", err)
+			}
+		})
+	}
+}
+
+func TestStaleStatus(t *testing.T) {
+	server := OpenVPNServer{Name: "v2", StatusFile: "../../example/version2.status"}
+	if _, err := server.Status(); err != nil {
+		t.Errorf("should have worked without a stale threshold: %v", err)
+	}
+	server.StaleAfter = time.Minute
+	if _, err := server.Status(); err == nil {
+		t.Errorf("should have failed on a stale status")
+	}
+}
+
+func TestStatusVersion(t *testing.T) {
+	server := OpenVPNServer{Name: "v2", StatusFile: "../../example/version2.status", StatusVersion: 2}
+	if _, err := server.Status(); err != nil {
+		t.Errorf("should have worked with the matching status-version: %v", err)
+	}
+	server.StatusVersion = 3
+	if _, err := server.Status(); errorReason(err) != ReasonUnknownFormat {
+		t.Errorf("should have failed on a different status-version: %v", err)
+	}
+	server = OpenVPNServer{Name: "statistics", StatusFile: "../../example/statistics.status", StatusVersion: 2}
+	if _, err := server.Status(); err != nil {
+		t.Errorf("statistics should be accepted with any status-version: %v", err)
+	}
+}
+
+func TestUp(t *testing.T) {
+	families := gatherFamilies(t, NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{
+			{Name: "v2", StatusFile: "../../example/version2.status"},
+			{Name: "stale", StatusFile: "../../example/version2.status", StaleAfter: time.Minute},
+			{Name: "missing", StatusFile: "../../example/missing.status"},
+		},
+		true, false, false, nil, nil,
+	))
+	up := make(map[string]float64)
+	for _, metric := range families["openvpn_up"].GetMetric() {
+		up[labelMap(metric.GetLabel())["server"]] = metric.GetGauge().GetValue()
+	}
+	expected := map[string]float64{"v2": 1, "stale": 0, "missing": 0}
+	for server, value := range expected {
+		if up[server] != value {
+			t.Errorf("expected openvpn_up of %s to be %v, got %v", server, value, up[server])
+		}
+	}
+	age := make(map[string]float64)
+	for _, metric := range families["openvpn_status_age_seconds"].GetMetric() {
+		age[labelMap(metric.GetLabel())["server"]] = metric.GetGauge().GetValue()
+	}
+	if len(age) != 2 || age["stale"] < time.Since(time.Date(2020, 4, 30, 13, 55, 44, 0, time.UTC)).Seconds()-60 {
+		t.Errorf("status age is not collected correctly: %v", age)
+	}
+	for _, metric := range families["openvpn_bytes_received"].GetMetric() {
+		if labelMap(metric.GetLabel())["server"] != "v2" {
+			t.Errorf("client metrics of stale servers should be suppressed")
+		}
+	}
+	for _, name := range []string{"openvpn_connections", "openvpn_last_updated", "openvpn_server_info"} {
+		servers := make(map[string]bool)
+		for _, metric := range families[name].GetMetric() {
+			servers[labelMap(metric.GetLabel())["server"]] = true
+		}
+		if !servers["v2"] || !servers["stale"] || servers["missing"] {
+			t.Errorf("expected %s of the readable servers including stale ones, got %v", name, servers)
+		}
+	}
+}
+
+func TestStatusFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	complete, err := ioutil.ReadFile("../../example/version2.status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := complete[:bytes.LastIndex(complete, []byte("END"))]
+	statusFile := filepath.Join(dir, "server.status")
+	neverComplete := filepath.Join(dir, "never.status")
+	if err := ioutil.WriteFile(neverComplete, truncated, 0644); err != nil {
+		t.Fatal(err)
+	}
+	collector := NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{
+			{Name: "server", StatusFile: statusFile},
+			{Name: "never", StatusFile: neverComplete},
+		},
+		false, false, false, nil, nil,
+	)
+	gauges := func(families map[string]*dto.MetricFamily, name string) map[string]float64 {
+		values := make(map[string]float64)
+		for _, metric := range families[name].GetMetric() {
+			values[labelMap(metric.GetLabel())["server"]] = metric.GetGauge().GetValue()
+		}
+		return values
+	}
+
+	if err := ioutil.WriteFile(statusFile, complete, 0644); err != nil {
+		t.Fatal(err)
+	}
+	families := gatherFamilies(t, collector)
+	connections := gauges(families, "openvpn_connections")["server"]
+	if fallback := gauges(families, "openvpn_status_fallback"); fallback["server"] != 0 {
+		t.Errorf("expected no fallback for a complete status file, got %v", fallback)
+	}
+	if up := gauges(families, "openvpn_up"); up["never"] != 0 {
+		t.Errorf("expected a truncated status file without a complete snapshot to be down, got %v", up)
+	}
+
+	if err := ioutil.WriteFile(statusFile, truncated, 0644); err != nil {
+		t.Fatal(err)
+	}
+	families = gatherFamilies(t, collector)
+	if up := gauges(families, "openvpn_up"); up["server"] != 1 {
+		t.Errorf("expected the last complete status to be exported, got %v", up)
+	}
+	if fallback := gauges(families, "openvpn_status_fallback"); fallback["server"] != 1 {
+		t.Errorf("expected the fallback to be flagged, got %v", fallback)
+	}
+	if current := gauges(families, "openvpn_connections")["server"]; current != connections {
+		t.Errorf("expected %v connections of the last complete status, got %v", connections, current)
+	}
+	collectionErrors := make(map[string]float64)
+	for _, metric := range families["openvpn_collection_error"].GetMetric() {
+		labels := labelMap(metric.GetLabel())
+		collectionErrors[labels["server"]+"/"+labels["reason"]] = metric.GetCounter().GetValue()
+	}
+	if collectionErrors["server/truncated"] != 1 || collectionErrors["never/truncated"] != 2 {
+		t.Errorf("expected truncated status files to be counted, got %v", collectionErrors)
+	}
+}
+
+var retryTruncatedTestCases = []struct {
+	scenarioName string
+	// results are returned by the reads in order, the last one repeatedly
+	results []error
+	reads   int
+	sleeps  []time.Duration
+}{
+	{"complete", []error{nil}, 1, nil},
+	{"other error", []error{os.ErrNotExist}, 1, nil},
+	{"complete after retry", []error{openvpn.ErrTruncated, openvpn.ErrTruncated, nil}, 3, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}},
+	{"truncated", []error{openvpn.ErrTruncated}, 5, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 30 * time.Millisecond}},
+}
+
+func TestRetryTruncated(t *testing.T) {
+	for _, tt := range retryTruncatedTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			reads := 0
+			var sleeps []time.Duration
+			_, err := retryTruncated(func() (*openvpn.Status, error) {
+				result := tt.results[len(tt.results)-1]
+				if reads < len(tt.results) {
+					result = tt.results[reads]
+				}
+				reads++
+				return &openvpn.Status{}, result
+			}, func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			})
+			if err != tt.results[len(tt.results)-1] {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if reads != tt.reads {
+				t.Errorf("expected %d reads, got %d", tt.reads, reads)
+			}
+			if len(sleeps) != len(tt.sleeps) {
+				t.Fatalf("expected backoffs %v, got %v", tt.sleeps, sleeps)
+			}
+			var total time.Duration
+			for i := range sleeps {
+				if sleeps[i] != tt.sleeps[i] {
+					t.Errorf("expected backoffs %v, got %v", tt.sleeps, sleeps)
+				}
+				total += sleeps[i]
+			}
+			if total > truncatedMaxBackoff {
+				t.Errorf("total backoff %v exceeds %v", total, truncatedMaxBackoff)
+			}
+		})
+	}
+}
+
+func TestErrorReason(t *testing.T) {
+	_, notFound := openvpn.ParseFile("../../example/missing.status")
+	_, unknownFormat := openvpn.ParseFile("../../example/server.conf")
+	_, connectionFailed := openvpn.ParseManagement("unix:/nonexistent/management.sock", "", time.Second)
+	stale := OpenVPNServer{StatusFile: "../../example/version2.status", StaleAfter: time.Minute}
+	_, staleErr := stale.Status()
+	errorReasonTestCases := []struct {
+		scenarioName string
+		err          error
+		reason       string
+	}{
+		{"file not found", notFound, ReasonFileNotFound},
+		{"permission denied", &os.PathError{Op: "open", Path: "status", Err: os.ErrPermission}, ReasonPermissionDenied},
+		{"unknown format", unknownFormat, ReasonUnknownFormat},
+		{"truncated", openvpn.ErrTruncated, ReasonTruncated},
+		{"stale", staleErr, ReasonStale},
+		{"connection failed", connectionFailed, ReasonConnectionFailed},
+		{"other", errors.New("other"), ReasonOther},
+	}
+	for _, tt := range errorReasonTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			if reason := errorReason(tt.err); reason != tt.reason {
+				t.Errorf("expected reason %s for %v, got %s", tt.reason, tt.err, reason)
+			}
+		})
+	}
+}
+
+func TestCollectPool(t *testing.T) {
+	families := gatherFamilies(t, NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{{Name: "v2", StatusFile: "../../example/version2.status", ConfigFile: "../../example/server.conf"}},
+		false, false, false, nil, nil,
+	))
+	expected := map[string]float64{
+		"openvpn_pool_size":              62,
+		"openvpn_max_clients":            100,
+		"openvpn_pool_persisted_leases":  3,
+		"openvpn_pool_utilization_ratio": 2.0 / 62,
+	}
+	for name, value := range expected {
+		family, ok := families[name]
+		if !ok {
+			t.Errorf("%s is not collected", name)
+			continue
+		}
+		if actual := family.GetMetric()[0].GetGauge().GetValue(); actual != value {
+			t.Errorf("expected %s to be %v, got %v", name, value, actual)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// PeerInfoCollector collects the client platform and version distribution from the
+// IV_* peer info of clients tracked via management interface notifications
+type PeerInfoCollector struct {
+	logger                log.Logger
+	collectClientPeerInfo bool
+	extraLabels           []string
+	OpenVPNServer         []OpenVPNServer
+	ClientsByVersion      *prometheus.Desc
+	ClientPeerInfo        *prometheus.Desc
+}
+
+type peerVersion struct {
+	platform   string
+	version    string
+	guiVersion string
+}
+
+// NewPeerInfoCollector returns a new PeerInfoCollector
+func NewPeerInfoCollector(logger log.Logger, openVPNServer []OpenVPNServer, collectClientPeerInfo bool) *PeerInfoCollector {
+	extraLabels := extraLabelNames(openVPNServer)
+	return &PeerInfoCollector{
+		logger:                logger,
+		OpenVPNServer:         openVPNServer,
+		collectClientPeerInfo: collectClientPeerInfo,
+		extraLabels:           extraLabels,
+
+		ClientsByVersion: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "clients_by_version"),
+			"Amount of currently connected clients by platform and client version",
+			append([]string{"server", "platform", "version", "gui_version"}, extraLabels...),
+			nil,
+		),
+		ClientPeerInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_peer_info"),
+			"A metric with a constant '1' value labeled by the peer info the client sent",
+			append([]string{"server", "common_name", "client_id", "platform", "version", "gui_version", "ssl"}, extraLabels...),
+			nil,
+		),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics collected by this Collector.
+func (c *PeerInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.ClientsByVersion
+	if c.collectClientPeerInfo {
+		ch <- c.ClientPeerInfo
+	}
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (c *PeerInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, ovpn := range c.OpenVPNServer {
+		if ovpn.Tracker == nil {
+			continue
+		}
+		status, err := ovpn.Tracker.Status()
+		if err != nil {
+			// errors are already reported by the OpenVPNCollector
+			level.Debug(c.logger).Log(
+				"msg", "skipping peer info",
+				"name", ovpn.Name,
+				"err", err,
+			)
+			continue
+		}
+		c.collect(ovpn, status, ch)
+	}
+}
+
+func (c *PeerInfoCollector) collect(ovpn OpenVPNServer, status *openvpn.Status, ch chan<- prometheus.Metric) {
+	clientsByVersion := make(map[peerVersion]int)
+	for _, client := range status.ClientList {
+		version := peerVersion{
+			platform:   peerInfoLabel(client.PeerInfo, "IV_PLAT"),
+			version:    peerInfoLabel(client.PeerInfo, "IV_VER"),
+			guiVersion: peerInfoLabel(client.PeerInfo, "IV_GUI_VER"),
+		}
+		clientsByVersion[version]++
+		if c.collectClientPeerInfo && !ovpn.DisableClientMetrics && client.CommonName != "UNDEF" {
+			ch <- prometheus.MustNewConstMetric(
+				c.ClientPeerInfo,
+				prometheus.GaugeValue,
+				1.0,
+				c.labelValues(
+					ovpn,
+					ovpn.Name,
+					client.CommonName,
+					client.ClientID,
+					version.platform,
+					version.version,
+					version.guiVersion,
+					peerInfoLabel(client.PeerInfo, "IV_SSL"),
+				)...,
+			)
+		}
+	}
+	for version, clients := range clientsByVersion {
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientsByVersion,
+			prometheus.GaugeValue,
+			float64(clients),
+			c.labelValues(
+				ovpn,
+				ovpn.Name,
+				version.platform,
+				version.version,
+				version.guiVersion,
+			)...,
+		)
+	}
+}
+
+func (c *PeerInfoCollector) labelValues(ovpn OpenVPNServer, values ...string) []string {
+	return extraLabelValues(c.extraLabels, ovpn, values)
+}
+
+func peerInfoLabel(peerInfo map[string]string, key string) string {
+	if value, ok := peerInfo[key]; ok && value != "" {
+		return value
+	}
+	return "unknown"
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+var peerInfoLabelTestCases = []struct {
+	scenarioName string
+	peerInfo     map[string]string
+	expected     string
+}{
+	{"available", map[string]string{"IV_PLAT": "win"}, "win"},
+	{"empty", map[string]string{"IV_PLAT": ""}, "unknown"},
+	{"missing", nil, "unknown"},
+}
+
+func TestPeerInfoLabel(t *testing.T) {
+	for _, tt := range peerInfoLabelTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			if peerInfoLabel(tt.peerInfo, "IV_PLAT") != tt.expected {
+				t.Errorf("Unexpected result")
+			}
+		})
+	}
+}
+
+// peerInfoNotifications establish a third client which sent its peer info
+const peerInfoNotifications = `>CLIENT:ESTABLISHED,2
+>CLIENT:ENV,common_name=user2
+>CLIENT:ENV,trusted_ip=1.2.3.9
+>CLIENT:ENV,trusted_port=1194
+>CLIENT:ENV,time_unix=1588254950
+>CLIENT:ENV,IV_VER=2.4.6
+>CLIENT:ENV,IV_PLAT=mac
+>CLIENT:ENV,IV_GUI_VER=net.tunnelblick.tunnelblick_5200_3.7.8__build_5200
+>CLIENT:ENV,IV_SSL=OpenSSL_1.0.2q__20_Nov_2018
+>CLIENT:ENV,END
+`
+
+// runTracker returns a tracker connected to a fake management interface with two clients of
+// the example status and a third client with peer info
+func runTracker(t *testing.T) *openvpn.ClientTracker {
+	responses := managementResponses(t)
+	responses["status 3"] += peerInfoNotifications
+	f := newFakeManagement(t, responses)
+	tracker := openvpn.NewClientTracker(f.Address(), "", time.Second, 5*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go tracker.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, err := tracker.Status(); err == nil && len(status.ClientList) == 3 {
+			return tracker
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("tracker did not receive the clients in time")
+	return nil
+}
+
+func TestPeerInfoCollector(t *testing.T) {
+	tracker := runTracker(t)
+	for _, collectClientPeerInfo := range []bool{false, true} {
+		families := gatherFamilies(t, NewPeerInfoCollector(
+			log.NewNopLogger(),
+			[]OpenVPNServer{
+				{Name: "mgmt", Tracker: tracker},
+				{Name: "file", StatusFile: "../../example/version2.status"},
+			},
+			collectClientPeerInfo,
+		))
+		clientsByVersion := make(map[string]float64)
+		for _, metric := range families["openvpn_clients_by_version"].GetMetric() {
+			labels := labelMap(metric.GetLabel())
+			if labels["server"] != "mgmt" {
+				t.Errorf("unexpected server: %v", labels)
+			}
+			clientsByVersion[labels["platform"]+"/"+labels["version"]+"/"+labels["gui_version"]] = metric.GetGauge().GetValue()
+		}
+		expected := map[string]float64{
+			"unknown/unknown/unknown":                                      2,
+			"mac/2.4.6/net.tunnelblick.tunnelblick_5200_3.7.8__build_5200": 1,
+		}
+		if len(clientsByVersion) != len(expected) {
+			t.Errorf("unexpected clients by version: %v", clientsByVersion)
+		}
+		for version, clients := range expected {
+			if clientsByVersion[version] != clients {
+				t.Errorf("expected %v clients of %s, got %v", clients, version, clientsByVersion[version])
+			}
+		}
+
+		peerInfo := families["openvpn_client_peer_info"].GetMetric()
+		if !collectClientPeerInfo {
+			if len(peerInfo) != 0 {
+				t.Errorf("client peer info should be opt-in")
+			}
+			continue
+		}
+		if len(peerInfo) != 3 {
+			t.Fatalf("expected the peer info of every client, got %d", len(peerInfo))
+		}
+		for _, metric := range peerInfo {
+			labels := labelMap(metric.GetLabel())
+			if labels["common_name"] == "user2" && (labels["client_id"] != "2" || labels["platform"] != "mac" || labels["ssl"] != "OpenSSL_1.0.2q__20_Nov_2018") {
+				t.Errorf("peer info is not labeled correctly: %v", labels)
+			}
+		}
+	}
+}
+
+func TestPeerInfoCollectorWithLabels(t *testing.T) {
+	families := gatherFamilies(t, NewPeerInfoCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{
+			{Name: "mgmt", Tracker: runTracker(t), Labels: map[string]string{"site": "fra1"}},
+			{Name: "other", Tracker: runTracker(t), Labels: map[string]string{"tier": "production"}},
+		},
+		true,
+	))
+	for _, name := range []string{"openvpn_clients_by_version", "openvpn_client_peer_info"} {
+		metrics := families[name].GetMetric()
+		if len(metrics) == 0 {
+			t.Errorf("expected %s metrics", name)
+		}
+		for _, metric := range metrics {
+			labels := labelMap(metric.GetLabel())
+			expected := map[string]string{"mgmt": "fra1/", "other": "/production"}[labels["server"]]
+			if labels["site"]+"/"+labels["tier"] != expected {
+				t.Errorf("%s is not labeled with the static labels of the server: %v", name, labels)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// PKICollector collects the certificates of easy-rsa PKIs from their index.txt database
+type PKICollector struct {
+	logger              log.Logger
+	PKI                 []PKI
+	Certificates        *prometheus.Desc
+	CertificateNotAfter *prometheus.Desc
+	CollectionError     *prometheus.CounterVec
+}
+
+// PKI contains the easy-rsa index.txt database to collect
+type PKI struct {
+	Name      string
+	IndexFile string
+}
+
+// NewPKICollector returns a new PKICollector
+func NewPKICollector(logger log.Logger, pki []PKI) *PKICollector {
+	return &PKICollector{
+		logger: logger,
+		PKI:    pki,
+
+		Certificates: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "pki", "certificates"),
+			"Amount of certificates in the PKI by status",
+			[]string{"pki", "status"},
+			nil,
+		),
+		CertificateNotAfter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "pki", "certificate_not_after_seconds"),
+			"Unix timestamp when the current certificate of the common name expires",
+			[]string{"pki", "common_name", "status"},
+			nil,
+		),
+		CollectionError: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: prometheus.BuildFQName(namespace, "pki", "collection_error"),
+				Help: "Error occurred during collection",
+			},
+			[]string{"pki"},
+		),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics collected by this Collector.
+func (c *PKICollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.Certificates
+	ch <- c.CertificateNotAfter
+	c.CollectionError.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (c *PKICollector) Collect(ch chan<- prometheus.Metric) {
+	for _, pki := range c.PKI {
+		entries, err := openvpn.ParseIndexFile(pki.IndexFile)
+		if err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "error parsing index file",
+				"name", pki.Name,
+				"indexFile", pki.IndexFile,
+				"err", err,
+			)
+			c.CollectionError.WithLabelValues(pki.Name).Inc()
+			continue
+		}
+		c.collect(pki, entries, time.Now(), ch)
+	}
+	c.CollectionError.Collect(ch)
+}
+
+func (c *PKICollector) collect(pki PKI, entries []openvpn.IndexEntry, now time.Time, ch chan<- prometheus.Metric) {
+	certificates := map[string]int{
+		openvpn.CertificateValid:   0,
+		openvpn.CertificateRevoked: 0,
+		openvpn.CertificateExpired: 0,
+	}
+	for _, entry := range entries {
+		certificates[certificateStatus(entry, now)]++
+	}
+	for status, count := range certificates {
+		ch <- prometheus.MustNewConstMetric(
+			c.Certificates,
+			prometheus.GaugeValue,
+			float64(count),
+			pki.Name, status,
+		)
+	}
+	for commonName, entry := range currentCertificates(entries, now) {
+		ch <- prometheus.MustNewConstMetric(
+			c.CertificateNotAfter,
+			prometheus.GaugeValue,
+			float64(entry.ExpiresAt.Unix()),
+			pki.Name, commonName, certificateStatus(entry, now),
+		)
+	}
+}
+
+// certificateStatus returns the status of the certificate, valid certificates past their
+// expiry are expired even if the database has not been updated yet
+func certificateStatus(entry openvpn.IndexEntry, now time.Time) string {
+	if entry.Status == openvpn.CertificateValid && entry.ExpiresAt.Before(now) {
+		return openvpn.CertificateExpired
+	}
+	return entry.Status
+}
+
+// currentCertificates returns the current certificate per common name, which is the valid
+// certificate expiring last or, if there is none, the certificate expiring last
+func currentCertificates(entries []openvpn.IndexEntry, now time.Time) map[string]openvpn.IndexEntry {
+	current := make(map[string]openvpn.IndexEntry)
+	for _, entry := range entries {
+		if entry.CommonName == "" {
+			continue
+		}
+		existing, ok := current[entry.CommonName]
+		if !ok {
+			current[entry.CommonName] = entry
+			continue
+		}
+		valid := certificateStatus(entry, now) == openvpn.CertificateValid
+		existingValid := certificateStatus(existing, now) == openvpn.CertificateValid
+		if (valid && !existingValid) || (valid == existingValid && entry.ExpiresAt.After(existing.ExpiresAt)) {
+			current[entry.CommonName] = entry
+		}
+	}
+	return current
+}
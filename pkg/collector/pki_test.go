@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPKICollector(t *testing.T) {
+	families := gatherFamilies(t, NewPKICollector(log.NewNopLogger(), []PKI{
+		{Name: "test", IndexFile: "../../example/index.txt"},
+		{Name: "missing", IndexFile: "../../example/missing.txt"},
+	}))
+
+	certificates := make(map[string]float64)
+	for _, metric := range families["openvpn_pki_certificates"].GetMetric() {
+		labels := labelMap(metric.GetLabel())
+		certificates[labels["status"]] = metric.GetGauge().GetValue()
+	}
+	// the valid certificate of alice expired in 2022 without the database being updated
+	expected := map[string]float64{"valid": 2, "revoked": 1, "expired": 2}
+	for status, count := range expected {
+		if certificates[status] != count {
+			t.Errorf("expected %v %s certificates, got %v", count, status, certificates[status])
+		}
+	}
+
+	notAfter := make(map[string]string)
+	for _, metric := range families["openvpn_pki_certificate_not_after_seconds"].GetMetric() {
+		labels := labelMap(metric.GetLabel())
+		notAfter[labels["common_name"]] = labels["status"]
+	}
+	expectedStatus := map[string]string{"server": "valid", "alice": "valid", "bob": "revoked", "carol": "expired"}
+	if len(notAfter) != len(expectedStatus) {
+		t.Errorf("Unexpected common names: %v", notAfter)
+	}
+	for commonName, status := range expectedStatus {
+		if notAfter[commonName] != status {
+			t.Errorf("expected certificate of %s to be %s, got %s", commonName, status, notAfter[commonName])
+		}
+	}
+
+	errors := families["openvpn_pki_collection_error"].GetMetric()
+	if len(errors) != 1 || errors[0].GetLabel()[0].GetValue() != "missing" {
+		t.Errorf("missing index is not reported")
+	}
+}
+
+func labelMap(labels []*dto.LabelPair) map[string]string {
+	values := make(map[string]string, len(labels))
+	for _, label := range labels {
+		values[label.GetName()] = label.GetValue()
+	}
+	return values
+}
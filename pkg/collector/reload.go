@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ReloadableCollector delegates to the collectors of the currently configured servers, which
+// are replaced atomically when the configuration is reloaded
+type ReloadableCollector struct {
+	mu                   sync.RWMutex
+	collectors           []prometheus.Collector
+	lastReloadSuccessful bool
+	lastReloadSuccess    time.Time
+
+	LastReloadSuccessful *prometheus.Desc
+	LastReloadSuccess    *prometheus.Desc
+}
+
+// NewReloadableCollector returns a new ReloadableCollector delegating to collectors
+func NewReloadableCollector(collectors ...prometheus.Collector) *ReloadableCollector {
+	return &ReloadableCollector{
+		collectors:           collectors,
+		lastReloadSuccessful: true,
+		lastReloadSuccess:    time.Now(),
+
+		LastReloadSuccessful: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "config_last_reload_successful"),
+			"Whether the last configuration reload attempt was successful",
+			nil,
+			nil,
+		),
+		LastReloadSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "config_last_reload_success_timestamp_seconds"),
+			"Unix timestamp of the last successful configuration reload",
+			nil,
+			nil,
+		),
+	}
+}
+
+// Reload replaces the collectors delegated to
+func (c *ReloadableCollector) Reload(collectors ...prometheus.Collector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collectors = collectors
+	c.lastReloadSuccessful = true
+	c.lastReloadSuccess = time.Now()
+}
+
+// ReloadFailed records a failed reload, the current collectors are kept
+func (c *ReloadableCollector) ReloadFailed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastReloadSuccessful = false
+}
+
+// Describe sends no descriptors, which makes the collector unchecked, as the metrics and
+// labels of the delegated collectors change with the configuration.
+func (c *ReloadableCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (c *ReloadableCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	collectors := c.collectors
+	successful := 0.0
+	if c.lastReloadSuccessful {
+		successful = 1.0
+	}
+	lastReloadSuccess := c.lastReloadSuccess
+	c.mu.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(
+		c.LastReloadSuccessful,
+		prometheus.GaugeValue,
+		successful,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.LastReloadSuccess,
+		prometheus.GaugeValue,
+		float64(lastReloadSuccess.Unix()),
+	)
+	for _, collector := range collectors {
+		collector.Collect(ch)
+	}
+}
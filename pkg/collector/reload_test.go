@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gatherFamilies(t *testing.T, c prometheus.Collector) map[string]*dto.MetricFamily {
+	r := prometheus.NewRegistry()
+	r.MustRegister(c)
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatalf("gathering failed: %v", err)
+	}
+	result := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		result[family.GetName()] = family
+	}
+	return result
+}
+
+func TestReloadableCollector(t *testing.T) {
+	c := NewReloadableCollector(NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{{Name: "v1", StatusFile: "../../example/version1.status"}},
+		true, false, false, nil, nil,
+	))
+	families := gatherFamilies(t, c)
+	if len(families["openvpn_connections"].GetMetric()) != 1 {
+		t.Errorf("metrics of the initial servers are not collected")
+	}
+	if families["openvpn_exporter_config_last_reload_successful"].GetMetric()[0].GetGauge().GetValue() != 1 {
+		t.Errorf("initial configuration should be reported as successful")
+	}
+
+	c.Reload(NewOpenVPNCollector(
+		log.NewNopLogger(),
+		[]OpenVPNServer{
+			{Name: "v2", StatusFile: "../../example/version2.status", Labels: map[string]string{"site": "fra1"}},
+			{Name: "v3", StatusFile: "../../example/version3.status"},
+		},
+		true, false, false, nil, nil,
+	))
+	families = gatherFamilies(t, c)
+	connections := families["openvpn_connections"].GetMetric()
+	if len(connections) != 2 || len(connections[0].GetLabel()) != 2 {
+		t.Errorf("metrics of the reloaded servers are not collected")
+	}
+
+	// the collector is unchecked, a pedantic registry accepts the metrics of the servers
+	// configured after the registration
+	r := prometheus.NewPedanticRegistry()
+	r.MustRegister(c)
+	if _, err := r.Gather(); err != nil {
+		t.Errorf("pedantic gathering failed: %v", err)
+	}
+
+	c.ReloadFailed()
+	families = gatherFamilies(t, c)
+	if families["openvpn_exporter_config_last_reload_successful"].GetMetric()[0].GetGauge().GetValue() != 0 {
+		t.Errorf("failed reload is not reported")
+	}
+	if len(families["openvpn_connections"].GetMetric()) != 2 {
+		t.Errorf("collectors should be kept on failed reloads")
+	}
+}
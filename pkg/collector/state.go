@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// ServerState contains the collection errors and the last complete status of a server. It is
+// kept across configuration reloads, so counters do not reset and the fallback to the last
+// complete status keeps working.
+type ServerState struct {
+	mu       sync.Mutex
+	errors   map[string]float64
+	snapshot *openvpn.Status
+}
+
+// NewServerState returns a new ServerState
+func NewServerState() *ServerState {
+	return &ServerState{
+		errors: make(map[string]float64),
+	}
+}
+
+// Errors returns the amount of collection errors by reason
+func (s *ServerState) Errors() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errors := make(map[string]float64, len(s.errors))
+	for reason, value := range s.errors {
+		errors[reason] = value
+	}
+	return errors
+}
+
+func (s *ServerState) addError(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[reason]++
+}
+
+// Snapshot returns the last complete status of the server
+func (s *ServerState) Snapshot() *openvpn.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot
+}
+
+func (s *ServerState) storeSnapshot(status *openvpn.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = status
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// ClientTotals accumulates the traffic of client sessions into totals per common name,
+// which survive disconnects and, if a state file is configured, exporter restarts.
+type ClientTotals struct {
+	mu        sync.Mutex
+	stateFile string
+	servers   map[string]*serverTotals
+	// lastSave is the time the state file was last written
+	lastSave time.Time
+	now      func() time.Time
+}
+
+// stateSaveInterval is the minimum interval in which byte count updates of active sessions
+// are written to the state file, started and finished sessions are written immediately
+const stateSaveInterval = time.Minute
+
+// Traffic reflects the amount of data transferred by a common name
+type Traffic struct {
+	BytesReceived float64 `json:"bytes_received"`
+	BytesSent     float64 `json:"bytes_sent"`
+}
+
+type serverTotals struct {
+	// Finished contains the traffic of all finished sessions per common name
+	Finished map[string]*Traffic `json:"finished"`
+	// Sessions contains the last seen traffic of the active sessions
+	Sessions map[string]*clientSession `json:"sessions"`
+}
+
+type clientSession struct {
+	CommonName     string  `json:"common_name"`
+	ConnectedSince int64   `json:"connected_since"`
+	BytesReceived  float64 `json:"bytes_received"`
+	BytesSent      float64 `json:"bytes_sent"`
+}
+
+// NewClientTotals returns a new ClientTotals, restoring the state of stateFile if it exists.
+// The state is kept in memory only if stateFile is empty.
+func NewClientTotals(stateFile string) (*ClientTotals, error) {
+	t := &ClientTotals{
+		stateFile: stateFile,
+		servers:   make(map[string]*serverTotals),
+		now:       time.Now,
+	}
+	if stateFile == "" {
+		return t, nil
+	}
+	data, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t.servers); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Observe updates the sessions of a server with the currently connected clients and returns
+// the totals per common name. A session is finished once it disappears, its connected since
+// changes or its byte counts go backwards. The state file is only written if the totals
+// changed.
+func (t *ClientTotals) Observe(server string, clients []openvpn.Client) (map[string]Traffic, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals, ok := t.servers[server]
+	if !ok {
+		totals = &serverTotals{
+			Finished: make(map[string]*Traffic),
+			Sessions: make(map[string]*clientSession),
+		}
+		t.servers[server] = totals
+	}
+
+	// changed is set if a session started or finished, updated if only byte counts changed
+	changed, updated := false, false
+	seen := make(map[string]bool, len(clients))
+	for _, client := range clients {
+		if client.CommonName == "UNDEF" {
+			continue
+		}
+		key := client.SessionKey()
+		seen[key] = true
+		current := &clientSession{
+			CommonName:     client.CommonName,
+			ConnectedSince: client.ConnectedSince.Unix(),
+			BytesReceived:  client.BytesReceived,
+			BytesSent:      client.BytesSent,
+		}
+		previous, ok := totals.Sessions[key]
+		switch {
+		case !ok:
+			changed = true
+		case isNewSession(previous, current):
+			totals.finish(previous)
+			changed = true
+		case *previous != *current:
+			updated = true
+		}
+		totals.Sessions[key] = current
+	}
+	for key, session := range totals.Sessions {
+		if !seen[key] {
+			totals.finish(session)
+			delete(totals.Sessions, key)
+			changed = true
+		}
+	}
+
+	result := make(map[string]Traffic, len(totals.Finished))
+	for commonName, traffic := range totals.Finished {
+		result[commonName] = *traffic
+	}
+	for _, session := range totals.Sessions {
+		traffic := result[session.CommonName]
+		traffic.BytesReceived += session.BytesReceived
+		traffic.BytesSent += session.BytesSent
+		result[session.CommonName] = traffic
+	}
+	if changed || (updated && t.now().Sub(t.lastSave) >= stateSaveInterval) {
+		return result, t.save()
+	}
+	return result, nil
+}
+
+func (s *serverTotals) finish(session *clientSession) {
+	traffic, ok := s.Finished[session.CommonName]
+	if !ok {
+		traffic = &Traffic{}
+		s.Finished[session.CommonName] = traffic
+	}
+	traffic.BytesReceived += session.BytesReceived
+	traffic.BytesSent += session.BytesSent
+}
+
+// save writes the state atomically to the state file, t.mu must be held
+func (t *ClientTotals) save() error {
+	if t.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(t.servers)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(t.stateFile), filepath.Base(t.stateFile))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), t.stateFile); err != nil {
+		return err
+	}
+	t.lastSave = t.now()
+	return nil
+}
+
+func isNewSession(previous *clientSession, current *clientSession) bool {
+	return previous.ConnectedSince != current.ConnectedSince ||
+		current.BytesReceived < previous.BytesReceived ||
+		current.BytesSent < previous.BytesSent
+}
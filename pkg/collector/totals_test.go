@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+func newTestClient(commonName string, port string, connectedSince int64, bytesReceived float64, bytesSent float64) openvpn.Client {
+	return openvpn.Client{
+		CommonName:     commonName,
+		RealAddress:    "1.2.3.4",
+		RealPort:       port,
+		ConnectedSince: time.Unix(connectedSince, 0),
+		BytesReceived:  bytesReceived,
+		BytesSent:      bytesSent,
+	}
+}
+
+var clientTotalsTestCases = []struct {
+	scenarioName string
+	snapshots    [][]openvpn.Client
+	expected     Traffic
+}{
+	{
+		"single session",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 10, 20)},
+			{newTestClient("foo", "1194", 100, 30, 40)},
+		},
+		Traffic{30, 40},
+	},
+	{
+		"disconnected session",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 10, 20)},
+			{},
+		},
+		Traffic{10, 20},
+	},
+	{
+		"reconnect with new connected since",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 10, 20)},
+			{newTestClient("foo", "1194", 200, 5, 5)},
+		},
+		Traffic{15, 25},
+	},
+	{
+		"reconnect with byte counts going backwards",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 10, 20)},
+			{newTestClient("foo", "1194", 100, 5, 25)},
+		},
+		Traffic{15, 45},
+	},
+	{
+		"reconnect from another port",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 10, 20)},
+			{newTestClient("foo", "1195", 200, 5, 5)},
+			{newTestClient("foo", "1195", 200, 6, 6)},
+		},
+		Traffic{16, 26},
+	},
+	{
+		"concurrent sessions",
+		[][]openvpn.Client{
+			{newTestClient("foo", "1194", 100, 10, 20), newTestClient("foo", "1195", 100, 1, 2)},
+		},
+		Traffic{11, 22},
+	},
+}
+
+func TestClientTotals(t *testing.T) {
+	for _, tt := range clientTotalsTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			totals, _ := NewClientTotals("")
+			var result map[string]Traffic
+			for _, snapshot := range tt.snapshots {
+				result, _ = totals.Observe("server", snapshot)
+			}
+			if result["foo"] != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, result["foo"])
+			}
+		})
+	}
+}
+
+func TestClientTotalsIgnoreUndef(t *testing.T) {
+	totals, _ := NewClientTotals("")
+	result, _ := totals.Observe("server", []openvpn.Client{newTestClient("UNDEF", "1194", 100, 10, 20)})
+	if len(result) != 0 {
+		t.Errorf("UNDEF clients should not be accounted")
+	}
+}
+
+func TestClientTotalsSurviveRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "totals.json")
+
+	totals, err := NewClientTotals(stateFile)
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if _, err := totals.Observe("server", []openvpn.Client{newTestClient("foo", "1194", 100, 10, 20)}); err != nil {
+		t.Fatalf("should have saved the state: %v", err)
+	}
+	if _, err := totals.Observe("server", []openvpn.Client{newTestClient("foo", "1194", 200, 1, 2)}); err != nil {
+		t.Fatalf("should have saved the state: %v", err)
+	}
+
+	restored, err := NewClientTotals(stateFile)
+	if err != nil {
+		t.Fatalf("should have restored the state: %v", err)
+	}
+	result, _ := restored.Observe("server", []openvpn.Client{newTestClient("foo", "1194", 200, 3, 4)})
+	if result["foo"] != (Traffic{13, 24}) {
+		t.Errorf("totals did not survive the restart: %+v", result["foo"])
+	}
+}
+
+func TestClientTotalsSaveOnlyChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "totals.json")
+	totals, err := NewClientTotals(stateFile)
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	totals.now = func() time.Time { return now }
+
+	steps := []struct {
+		scenarioName string
+		clients      []openvpn.Client
+		advance      time.Duration
+		saved        bool
+	}{
+		{"started session", []openvpn.Client{newTestClient("foo", "1194", 100, 10, 20)}, 0, true},
+		{"unchanged", []openvpn.Client{newTestClient("foo", "1194", 100, 10, 20)}, 15 * time.Second, false},
+		{"updated byte counts", []openvpn.Client{newTestClient("foo", "1194", 100, 30, 40)}, 15 * time.Second, false},
+		{"updated byte counts after the interval", []openvpn.Client{newTestClient("foo", "1194", 100, 31, 41)}, 30 * time.Second, true},
+		{"finished session", nil, time.Second, true},
+		{"no sessions", nil, 5 * time.Minute, false},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if _, err := totals.Observe("server", step.clients); err != nil {
+			t.Fatalf("%s: should have worked: %v", step.scenarioName, err)
+		}
+		_, err := os.Stat(stateFile)
+		if saved := err == nil; saved != step.saved {
+			t.Errorf("%s: expected the state file to be written %v, got %v", step.scenarioName, step.saved, saved)
+		}
+		os.Remove(stateFile)
+	}
+}
+
+func TestClientTotalsFailOnCorruptState(t *testing.T) {
+	file, err := ioutil.TempFile("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("{corrupt")
+	file.Close()
+	if _, err := NewClientTotals(file.Name()); err == nil {
+		t.Errorf("should have failed on a corrupt state file")
+	}
+}
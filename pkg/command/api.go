@@ -0,0 +1,177 @@
+package command
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/collector"
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// apiServer is the JSON representation of the status of a server
+type apiServer struct {
+	Name       string         `json:"name"`
+	UpdatedAt  *time.Time     `json:"updated_at,omitempty"`
+	ServerInfo *apiServerInfo `json:"server_info,omitempty"`
+	Clients    []apiClient    `json:"clients"`
+	Routes     []apiRoute     `json:"routes"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type apiServerInfo struct {
+	Version           string `json:"version,omitempty"`
+	Arch              string `json:"arch,omitempty"`
+	AdditionalInfo    string `json:"additional_info,omitempty"`
+	ManagementVersion string `json:"management_version,omitempty"`
+}
+
+type apiClient struct {
+	CommonName         string    `json:"common_name"`
+	RealAddress        string    `json:"real_address"`
+	RealPort           string    `json:"real_port,omitempty"`
+	Protocol           string    `json:"protocol,omitempty"`
+	VirtualAddress     string    `json:"virtual_address,omitempty"`
+	VirtualIPv6Address string    `json:"virtual_ipv6_address,omitempty"`
+	Username           string    `json:"username,omitempty"`
+	ClientID           string    `json:"client_id,omitempty"`
+	PeerID             string    `json:"peer_id,omitempty"`
+	BytesReceived      float64   `json:"bytes_received"`
+	BytesSent          float64   `json:"bytes_sent"`
+	ConnectedSince     time.Time `json:"connected_since"`
+}
+
+type apiRoute struct {
+	VirtualAddress string    `json:"virtual_address"`
+	CommonName     string    `json:"common_name"`
+	RealAddress    string    `json:"real_address"`
+	LastRef        time.Time `json:"last_ref"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// handleAPIServers serves the status of all servers at /api/v1/servers, the clients and routes
+// are filtered by the common_name query parameters
+func (s *servers) handleAPIServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+		return
+	}
+	commonNames := r.URL.Query()["common_name"]
+	result := []apiServer{}
+	for _, ovpn := range s.configured() {
+		result = append(result, newAPIServer(ovpn, commonNames))
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// handleAPIServer serves the clients of a server at /api/v1/servers/{name}/clients, filtered
+// by the common_name query parameters
+func (s *servers) handleAPIServer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+		return
+	}
+	i := strings.Index(r.URL.Path, "/api/v1/servers/")
+	parts := strings.Split(r.URL.Path[i+len("/api/v1/servers/"):], "/")
+	if len(parts) != 2 || parts[1] != "clients" {
+		s.writeJSON(w, http.StatusNotFound, apiError{"not found"})
+		return
+	}
+	for _, ovpn := range s.configured() {
+		if ovpn.Name != parts[0] {
+			continue
+		}
+		server := newAPIServer(ovpn, r.URL.Query()["common_name"])
+		if server.Error != "" {
+			s.writeJSON(w, http.StatusServiceUnavailable, apiError{server.Error})
+			return
+		}
+		s.writeJSON(w, http.StatusOK, server.Clients)
+		return
+	}
+	s.writeJSON(w, http.StatusNotFound, apiError{"unknown server " + parts[0]})
+}
+
+func (s *servers) writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		level.Debug(s.logger).Log(
+			"msg", "error writing api response",
+			"err", err,
+		)
+	}
+}
+
+func newAPIServer(ovpn collector.OpenVPNServer, commonNames []string) apiServer {
+	server := apiServer{
+		Name:    ovpn.Name,
+		Clients: []apiClient{},
+		Routes:  []apiRoute{},
+	}
+	status, err := ovpn.Status()
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	server.UpdatedAt = &status.UpdatedAt
+	server.ServerInfo = &apiServerInfo{
+		Version:           status.ServerInfo.Version,
+		Arch:              status.ServerInfo.Arch,
+		AdditionalInfo:    status.ServerInfo.AdditionalInfo,
+		ManagementVersion: status.ServerInfo.ManagementVersion,
+	}
+	for _, client := range status.ClientList {
+		if matchesCommonName(client.CommonName, commonNames) {
+			server.Clients = append(server.Clients, newAPIClient(client))
+		}
+	}
+	for _, route := range status.Routes {
+		if matchesCommonName(route.CommonName, commonNames) {
+			server.Routes = append(server.Routes, apiRoute{
+				VirtualAddress: route.VirtualAddress,
+				CommonName:     route.CommonName,
+				RealAddress:    route.RealAddress,
+				LastRef:        route.LastRef,
+			})
+		}
+	}
+	return server
+}
+
+func newAPIClient(client openvpn.Client) apiClient {
+	return apiClient{
+		CommonName:         client.CommonName,
+		RealAddress:        client.RealAddress,
+		RealPort:           client.RealPort,
+		Protocol:           client.Protocol,
+		VirtualAddress:     client.VirtualAddress,
+		VirtualIPv6Address: client.VirtualIPv6Address,
+		Username:           client.Username,
+		ClientID:           client.ClientID,
+		PeerID:             client.PeerID,
+		BytesReceived:      client.BytesReceived,
+		BytesSent:          client.BytesSent,
+		ConnectedSince:     client.ConnectedSince,
+	}
+}
+
+// matchesCommonName reports whether the common name is one of the filtered common names, all
+// common names match if no filter is given
+func matchesCommonName(commonName string, commonNames []string) bool {
+	if len(commonNames) == 0 {
+		return true
+	}
+	for _, name := range commonNames {
+		if name == commonName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/collector"
+)
+
+func newTestServers() *servers {
+	return &servers{
+		logger: log.NewNopLogger(),
+		openVPNServers: []collector.OpenVPNServer{
+			{Name: "v2", StatusFile: "../../example/version2.status"},
+			{Name: "missing", StatusFile: "../../example/missing.status"},
+		},
+	}
+}
+
+var apiServerTestCases = []struct {
+	scenarioName string
+	method       string
+	path         string
+	code         int
+	// clients is the expected amount of clients of a successful response
+	clients int
+}{
+	{"clients", http.MethodGet, "/api/v1/servers/v2/clients", http.StatusOK, 2},
+	{"filtered clients", http.MethodGet, "/api/v1/servers/v2/clients?common_name=test1@localhost", http.StatusOK, 1},
+	{"several filters", http.MethodGet, "/api/v1/servers/v2/clients?common_name=test1@localhost&common_name=test@localhost", http.StatusOK, 2},
+	{"unknown common name", http.MethodGet, "/api/v1/servers/v2/clients?common_name=unknown", http.StatusOK, 0},
+	{"below root path", http.MethodGet, "/openvpn/api/v1/servers/v2/clients", http.StatusOK, 2},
+	{"unknown server", http.MethodGet, "/api/v1/servers/unknown/clients", http.StatusNotFound, 0},
+	{"server without clients", http.MethodGet, "/api/v1/servers/v2", http.StatusNotFound, 0},
+	{"unknown resource", http.MethodGet, "/api/v1/servers/v2/routes", http.StatusNotFound, 0},
+	{"trailing path", http.MethodGet, "/api/v1/servers/v2/clients/test", http.StatusNotFound, 0},
+	{"unreadable status", http.MethodGet, "/api/v1/servers/missing/clients", http.StatusServiceUnavailable, 0},
+	{"method not allowed", http.MethodPost, "/api/v1/servers/v2/clients", http.StatusMethodNotAllowed, 0},
+}
+
+func TestHandleAPIServer(t *testing.T) {
+	s := newTestServers()
+	for _, tt := range apiServerTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handleAPIServer(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+			}
+			if tt.code != http.StatusOK {
+				var response apiError
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error == "" {
+					t.Errorf("expected a JSON error, got %s", w.Body.String())
+				}
+				return
+			}
+			var clients []apiClient
+			if err := json.Unmarshal(w.Body.Bytes(), &clients); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if len(clients) != tt.clients {
+				t.Errorf("expected %d clients, got %d", tt.clients, len(clients))
+			}
+		})
+	}
+}
+
+func TestHandleAPIServers(t *testing.T) {
+	s := newTestServers()
+	w := httptest.NewRecorder()
+	s.handleAPIServers(w, httptest.NewRequest(http.MethodGet, "/api/v1/servers?common_name=test@localhost", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var result []apiServer
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(result) != 2 || result[0].Name != "v2" || result[1].Name != "missing" {
+		t.Fatalf("unexpected servers: %+v", result)
+	}
+	v2 := result[0]
+	if v2.Error != "" || v2.UpdatedAt == nil || v2.ServerInfo == nil || v2.ServerInfo.Version != "2.4.4" {
+		t.Errorf("unexpected server: %+v", v2)
+	}
+	if len(v2.Clients) != 1 || v2.Clients[0].CommonName != "test@localhost" {
+		t.Errorf("clients are not filtered by common name: %+v", v2.Clients)
+	}
+	if len(v2.Routes) != 1 || v2.Routes[0].CommonName != "test@localhost" {
+		t.Errorf("routes are not filtered by common name: %+v", v2.Routes)
+	}
+	missing := result[1]
+	if missing.Error == "" || missing.UpdatedAt != nil || missing.Clients == nil || len(missing.Clients) != 0 {
+		t.Errorf("unreadable server should report its error: %+v", missing)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAPIServers(w, httptest.NewRequest(http.MethodDelete, "/api/v1/servers", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
@@ -1,9 +1,14 @@
 package command
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -14,6 +19,7 @@ import (
 	"github.com/patrickjahns/openvpn_exporter/pkg/collector"
 	"github.com/patrickjahns/openvpn_exporter/pkg/config"
 	"github.com/patrickjahns/openvpn_exporter/pkg/version"
+	"github.com/patrickjahns/openvpn_exporter/pkg/webhook"
 )
 
 // Run parses the command line arguments and executes the program.
@@ -44,6 +50,12 @@ func Run() error {
 	}
 
 	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:        "config.file",
+			Usage:       "YAML configuration file defining the OpenVPN servers to export, flags override its settings",
+			EnvVars:     []string{"OPENVPN_EXPORTER_CONFIG_FILE"},
+			Destination: &cfg.File,
+		},
 		&cli.StringFlag{
 			Name:        "web.address",
 			Aliases:     []string{"web.listen-address"},
@@ -67,17 +79,189 @@ func Run() error {
 			EnvVars:     []string{"OPENVPN_EXPORTER_WEB_ROOT"},
 			Destination: &cfg.Server.Root,
 		},
+		&cli.BoolFlag{
+			Name:        "web.enable-api",
+			Value:       false,
+			Usage:       "Enables the JSON API listing the clients of the servers below /api/v1",
+			EnvVars:     []string{"OPENVPN_EXPORTER_WEB_ENABLE_API"},
+			Destination: &cfg.Server.EnableAPI,
+		},
+		&cli.BoolFlag{
+			Name:        "web.enable-dashboard",
+			Value:       false,
+			Usage:       "Enables the dashboard listing the servers and the common names and addresses of their clients on the root path",
+			EnvVars:     []string{"OPENVPN_EXPORTER_WEB_ENABLE_DASHBOARD"},
+			Destination: &cfg.Server.EnableDashboard,
+		},
+		&cli.StringSliceFlag{
+			Name:    "status-file",
+			Usage:   "The OpenVPN status file(s) to export (example test:./example/version1.status )",
+			EnvVars: []string{"OPENVPN_EXPORTER_STATUS_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:        "status.max-age",
+			Value:       0,
+			Usage:       "Marks the status of servers as stale if it has not been updated for the duration, suppressing their metrics and reporting openvpn_up 0 (0 disables)",
+			EnvVars:     []string{"OPENVPN_EXPORTER_STATUS_MAX_AGE"},
+			Destination: &cfg.StatusCollector.MaxAge,
+		},
 		&cli.StringSliceFlag{
-			Name:     "status-file",
-			Usage:    "The OpenVPN status file(s) to export (example test:./example/version1.status )",
-			EnvVars:  []string{"OPENVPN_EXPORTER_STATUS_FILE"},
-			Required: true,
+			Name:    "management.address",
+			Usage:   "The OpenVPN management interface(s) to query (example test:127.0.0.1:7505 or test:unix:/run/openvpn/server.sock )",
+			EnvVars: []string{"OPENVPN_EXPORTER_MANAGEMENT_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "management.password",
+			Usage:       "Password for the OpenVPN management interface(s)",
+			EnvVars:     []string{"OPENVPN_EXPORTER_MANAGEMENT_PASSWORD"},
+			Destination: &cfg.StatusCollector.Management.Password,
+		},
+		&cli.DurationFlag{
+			Name:        "management.timeout",
+			Value:       5 * time.Second,
+			Usage:       "Timeout for connecting to and querying the OpenVPN management interface(s)",
+			EnvVars:     []string{"OPENVPN_EXPORTER_MANAGEMENT_TIMEOUT"},
+			Destination: &cfg.StatusCollector.Management.Timeout,
+		},
+		&cli.BoolFlag{
+			Name:        "management.events",
+			Value:       false,
+			Usage:       "Keeps a long-lived connection to the OpenVPN management interface(s) and tracks clients via real-time notifications",
+			EnvVars:     []string{"OPENVPN_EXPORTER_MANAGEMENT_EVENTS"},
+			Destination: &cfg.StatusCollector.Management.Events,
+		},
+		&cli.DurationFlag{
+			Name:        "management.bytecount-interval",
+			Value:       5 * time.Second,
+			Usage:       "Interval of the per client bytecount notifications when tracking clients via real-time notifications",
+			EnvVars:     []string{"OPENVPN_EXPORTER_MANAGEMENT_BYTECOUNT_INTERVAL"},
+			Destination: &cfg.StatusCollector.Management.BytecountInterval,
+		},
+		&cli.StringSliceFlag{
+			Name:    "openvpn-config",
+			Usage:   "The OpenVPN config file(s) to export the pool capacity of a server from (example test:/etc/openvpn/server/test.conf )",
+			EnvVars: []string{"OPENVPN_EXPORTER_OPENVPN_CONFIG"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "openvpn-log",
+			Usage:   "The OpenVPN log file(s) to count authentication failures and disconnects of a server from (example test:/var/log/openvpn/test.log )",
+			EnvVars: []string{"OPENVPN_EXPORTER_OPENVPN_LOG"},
+		},
+		&cli.BoolFlag{
+			Name:        "enable-discovery",
+			Value:       false,
+			Usage:       "Discovers OpenVPN instances from their config files (status, status-version and management directives)",
+			EnvVars:     []string{"OPENVPN_EXPORTER_ENABLE_DISCOVERY"},
+			Destination: &cfg.StatusCollector.Discovery.Enabled,
+		},
+		&cli.StringSliceFlag{
+			Name:    "discovery.glob",
+			Value:   cli.NewStringSlice("/etc/openvpn/server/*.conf", "/etc/openvpn/*.conf"),
+			Usage:   "Glob patterns of the OpenVPN config files to discover, the basename is used as server name",
+			EnvVars: []string{"OPENVPN_EXPORTER_DISCOVERY_GLOB"},
+		},
+		&cli.DurationFlag{
+			Name:        "discovery.interval",
+			Value:       time.Minute,
+			Usage:       "Interval to rescan the OpenVPN config files for new or removed instances",
+			EnvVars:     []string{"OPENVPN_EXPORTER_DISCOVERY_INTERVAL"},
+			Destination: &cfg.StatusCollector.Discovery.Interval,
 		},
 		&cli.BoolFlag{
 			Name:    "disable-client-metrics",
 			Usage:   "Disables per client (bytes_received, bytes_sent, connected_since) metrics",
 			EnvVars: []string{"OPENVPN_EXPORTER_DISABLE_CLIENT_METRICS"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "duplicate-cn-policy",
+			Value:   cli.NewStringSlice(collector.DuplicatePolicyDrop),
+			Usage:   "How sessions sharing a common name are exported: drop, sum or split, optionally per server (example test:sum )",
+			EnvVars: []string{"OPENVPN_EXPORTER_DUPLICATE_CN_POLICY"},
+		},
+		&cli.BoolFlag{
+			Name:        "enable-client-info",
+			Value:       false,
+			Usage:       "Enables the per client info metric (virtual address, username, client id, peer id)",
+			EnvVars:     []string{"OPENVPN_EXPORTER_ENABLE_CLIENT_INFO"},
+			Destination: &cfg.StatusCollector.ExportClientInfo,
+		},
+		&cli.BoolFlag{
+			Name:        "enable-client-peer-info",
+			Value:       false,
+			Usage:       "Enables the per client peer info metric (platform, version, gui version, ssl), requires --management.events",
+			EnvVars:     []string{"OPENVPN_EXPORTER_ENABLE_CLIENT_PEER_INFO"},
+			Destination: &cfg.StatusCollector.ExportPeerInfo,
+		},
+		&cli.BoolFlag{
+			Name:        "enable-protocol-metrics",
+			Value:       false,
+			Usage:       "Enables the amount of connections by transport protocol (udp4, udp6, tcp4, tcp6) metric",
+			EnvVars:     []string{"OPENVPN_EXPORTER_ENABLE_PROTOCOL_METRICS"},
+			Destination: &cfg.StatusCollector.ExportProtocols,
+		},
+		&cli.BoolFlag{
+			Name:        "enable-client-totals",
+			Value:       false,
+			Usage:       "Enables per common name traffic counters (bytes_received_total, bytes_sent_total) which survive disconnects",
+			EnvVars:     []string{"OPENVPN_EXPORTER_ENABLE_CLIENT_TOTALS"},
+			Destination: &cfg.StatusCollector.ClientTotals.Enabled,
+		},
+		&cli.StringFlag{
+			Name:        "client-totals.state-file",
+			Usage:       "File to persist the per common name traffic counters across exporter restarts",
+			EnvVars:     []string{"OPENVPN_EXPORTER_CLIENT_TOTALS_STATE_FILE"},
+			Destination: &cfg.StatusCollector.ClientTotals.StateFile,
+		},
+		&cli.BoolFlag{
+			Name:        "enable-certificate-metrics",
+			Value:       false,
+			Usage:       "Enables the expiry metrics of the ca, cert and crl-verify files of servers with a known OpenVPN config",
+			EnvVars:     []string{"OPENVPN_EXPORTER_ENABLE_CERTIFICATE_METRICS"},
+			Destination: &cfg.StatusCollector.Certificates.Enabled,
+		},
+		&cli.StringSliceFlag{
+			Name:    "certificate.file",
+			Usage:   "Additional PEM certificate or CRL file(s) to export the expiry of",
+			EnvVars: []string{"OPENVPN_EXPORTER_CERTIFICATE_FILE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "pki.index",
+			Usage:   "Easy-rsa index.txt database(s) to export certificate counts and per common name expiry from (example pki:/etc/openvpn/easy-rsa/pki/index.txt)",
+			EnvVars: []string{"OPENVPN_EXPORTER_PKI_INDEX"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "webhook.url",
+			Usage:   "Webhook URL(s) to post a JSON event to when a client connects or disconnects",
+			EnvVars: []string{"OPENVPN_EXPORTER_WEBHOOK_URL"},
+		},
+		&cli.DurationFlag{
+			Name:        "webhook.interval",
+			Value:       10 * time.Second,
+			Usage:       "Interval in which the status of the servers is checked for connected and disconnected clients",
+			EnvVars:     []string{"OPENVPN_EXPORTER_WEBHOOK_INTERVAL"},
+			Destination: &cfg.StatusCollector.Webhook.Interval,
+		},
+		&cli.IntFlag{
+			Name:        "webhook.queue-size",
+			Value:       1000,
+			Usage:       "Maximum amount of queued webhook events, further events are dropped",
+			EnvVars:     []string{"OPENVPN_EXPORTER_WEBHOOK_QUEUE_SIZE"},
+			Destination: &cfg.StatusCollector.Webhook.QueueSize,
+		},
+		&cli.IntFlag{
+			Name:        "webhook.retries",
+			Value:       3,
+			Usage:       "Amount of retries of a failed webhook delivery",
+			EnvVars:     []string{"OPENVPN_EXPORTER_WEBHOOK_RETRIES"},
+			Destination: &cfg.StatusCollector.Webhook.Retries,
+		},
+		&cli.DurationFlag{
+			Name:        "webhook.timeout",
+			Value:       5 * time.Second,
+			Usage:       "Timeout of a webhook delivery",
+			EnvVars:     []string{"OPENVPN_EXPORTER_WEBHOOK_TIMEOUT"},
+			Destination: &cfg.StatusCollector.Webhook.Timeout,
+		},
 		&cli.BoolFlag{
 			Name:        "enable-golang-metrics",
 			Value:       false,
@@ -96,18 +280,45 @@ func Run() error {
 
 	app.Before = func(c *cli.Context) error {
 		cfg.StatusCollector.StatusFile = c.StringSlice("status-file")
+		cfg.StatusCollector.Management.Address = c.StringSlice("management.address")
+		cfg.StatusCollector.Discovery.Globs = c.StringSlice("discovery.glob")
+		cfg.StatusCollector.OpenVPNConfig = c.StringSlice("openvpn-config")
+		cfg.StatusCollector.OpenVPNLog = c.StringSlice("openvpn-log")
+		cfg.StatusCollector.Certificates.Files = c.StringSlice("certificate.file")
+		cfg.StatusCollector.PKIIndex = c.StringSlice("pki.index")
+		cfg.StatusCollector.Webhook.URLs = c.StringSlice("webhook.url")
 		cfg.StatusCollector.ExportClientMetrics = !c.Bool("disable-client-metrics")
+		cfg.StatusCollector.DuplicatePolicy = collector.DuplicatePolicyDrop
+		cfg.StatusCollector.DuplicatePolicies = make(map[string]string)
+		for _, duplicatePolicy := range c.StringSlice("duplicate-cn-policy") {
+			serverName, policy := parseDuplicatePolicySlice(duplicatePolicy)
+			if !collector.IsDuplicatePolicy(policy) {
+				return fmt.Errorf("unknown duplicate common name policy %q, must be one of %s", policy, strings.Join(collector.DuplicatePolicies, ", "))
+			}
+			if serverName == "" {
+				cfg.StatusCollector.DuplicatePolicy = policy
+			} else {
+				cfg.StatusCollector.DuplicatePolicies[serverName] = policy
+			}
+		}
+		servers, err := loadServers(c, cfg, setupLogging(cfg))
+		if err != nil {
+			return err
+		}
+		cfg.StatusCollector.Servers = servers
 		return nil
 	}
 
 	app.Action = func(c *cli.Context) error {
-		return run(cfg)
+		return run(cfg, func(logger log.Logger) ([]config.ServerConfig, error) {
+			return loadServers(c, cfg, logger)
+		})
 	}
 
 	return app.Run(os.Args)
 }
 
-func run(cfg *config.Config) error {
+func run(cfg *config.Config, load func(log.Logger) ([]config.ServerConfig, error)) error {
 	// setup logging
 	logger := setupLogging(cfg)
 	level.Info(logger).Log(
@@ -117,7 +328,11 @@ func run(cfg *config.Config) error {
 		"buildDate", version.BuildDate,
 		"goVersion", version.GoVersion,
 	)
-	var openVPServers []collector.OpenVPNServer
+	if len(cfg.StatusCollector.Servers) == 0 && !cfg.StatusCollector.Discovery.Enabled {
+		level.Warn(logger).Log(
+			"msg", "no servers configured, servers can be added by reloading the configuration",
+		)
+	}
 	r := prometheus.NewRegistry()
 	if cfg.ExportGoMetrics {
 		// enable profiler
@@ -132,33 +347,66 @@ func run(cfg *config.Config) error {
 		version.GoVersion,
 		version.Started,
 	))
-	for _, statusFile := range cfg.StatusCollector.StatusFile {
-		serverName, statusFile := parseStatusFileSlice(statusFile)
-		level.Info(logger).Log(
-			"msg", "registering collector for",
-			"serverName", serverName,
-			"statusFile", statusFile,
+	var clientTotals *collector.ClientTotals
+	if cfg.StatusCollector.ClientTotals.Enabled {
+		var err error
+		clientTotals, err = collector.NewClientTotals(cfg.StatusCollector.ClientTotals.StateFile)
+		if err != nil {
+			level.Error(logger).Log(
+				"msg", "error loading client totals state",
+				"stateFile", cfg.StatusCollector.ClientTotals.StateFile,
+				"err", err,
+			)
+			return err
+		}
+	}
+	servers := newServers(logger, cfg, load, clientTotals, collector.NewSessionChurn())
+	r.MustRegister(servers.collector)
+	go servers.watchSignals(reloadSignals())
+	if cfg.StatusCollector.Discovery.Enabled {
+		go servers.watchDiscovery(cfg.StatusCollector.Discovery.Interval)
+	}
+	if len(cfg.StatusCollector.Webhook.URLs) > 0 {
+		notifier := webhook.NewNotifier(
+			logger,
+			cfg.StatusCollector.Webhook.URLs,
+			cfg.StatusCollector.Webhook.QueueSize,
+			cfg.StatusCollector.Webhook.Retries,
+			cfg.StatusCollector.Webhook.Timeout,
 		)
-		openVPServers = append(openVPServers, collector.OpenVPNServer{Name: serverName, StatusFile: statusFile, ParseError: 0})
+		r.MustRegister(notifier)
+		go notifier.Run(context.Background())
+		go servers.watchSessions(notifier, cfg.StatusCollector.Webhook.Interval)
 	}
-	r.MustRegister(collector.NewOpenVPNCollector(
-		logger,
-		openVPServers,
-		cfg.StatusCollector.ExportClientMetrics,
-	))
 
 	http.Handle(cfg.Server.Path,
 		promhttp.HandlerFor(r, promhttp.HandlerOpts{}),
 	)
-	http.HandleFunc(cfg.Server.Root, func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte(`<html>
+	http.HandleFunc(path.Join(cfg.Server.Root, "/-/reload"), servers.handleReload)
+	if cfg.Server.EnableAPI {
+		http.HandleFunc(path.Join(cfg.Server.Root, "/api/v1/servers"), servers.handleAPIServers)
+		http.HandleFunc(path.Join(cfg.Server.Root, "/api/v1/servers")+"/", servers.handleAPIServer)
+	}
+	if cfg.Server.EnableDashboard {
+		dashboard := servers.handleDashboard(cfg.Server.Path, version.Version)
+		http.HandleFunc(cfg.Server.Root, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != cfg.Server.Root {
+				http.NotFound(w, r)
+				return
+			}
+			dashboard(w, r)
+		})
+	} else {
+		http.HandleFunc(cfg.Server.Root, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`<html>
 			<head><title>OpenVPN Exporter</title></head>
 			<body>
 			<h1>OpenVPN exporter</h1>
 			<p><a href="/metrics">Metrics</a></p>
 			</body>
 			</html>`))
-	})
+		})
+	}
 
 	level.Info(logger).Log("msg", "Listening on", "addr", cfg.Server.Addr)
 	if err := http.ListenAndServe(cfg.Server.Addr, nil); err != nil {
@@ -176,6 +424,145 @@ func parseStatusFileSlice(statusFile string) (string, string) {
 	return parts[0], parts[0]
 }
 
+// parseDuplicatePolicySlice splits server:policy into server name and policy, the server
+// name is empty for the default policy
+func parseDuplicatePolicySlice(duplicatePolicy string) (string, string) {
+	parts := strings.SplitN(duplicatePolicy, ":", 2)
+	if len(parts) > 1 {
+		return parts[0], parts[1]
+	}
+	return "", parts[0]
+}
+
+// loadServers merges the servers of the configuration file and the discovered servers with
+// the servers and settings given as flags or environment variables. Flags take precedence
+// over the file, which takes precedence over discovered servers of the same name.
+func loadServers(c *cli.Context, cfg *config.Config, logger log.Logger) ([]config.ServerConfig, error) {
+	var servers []config.ServerConfig
+	if cfg.File != "" {
+		file, err := config.LoadFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		servers = file.Servers
+	}
+	if cfg.StatusCollector.Discovery.Enabled {
+		discovered, err := discoverServers(logger, cfg.StatusCollector.Discovery.Globs)
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range discovered {
+			if !hasServer(servers, server.Name) {
+				servers = append(servers, server)
+			}
+		}
+	}
+	defaultPolicySet := false
+	if c.IsSet("duplicate-cn-policy") {
+		for _, duplicatePolicy := range c.StringSlice("duplicate-cn-policy") {
+			if serverName, _ := parseDuplicatePolicySlice(duplicatePolicy); serverName == "" {
+				defaultPolicySet = true
+			}
+		}
+	}
+	for _, statusFile := range cfg.StatusCollector.StatusFile {
+		serverName, statusFile := parseStatusFileSlice(statusFile)
+		server := serverConfig(&servers, serverName)
+		server.StatusFile = statusFile
+		server.StatusVersion = 0
+		server.ManagementAddress = ""
+	}
+	for _, managementAddress := range cfg.StatusCollector.Management.Address {
+		serverName, managementAddress := parseManagementAddressSlice(managementAddress)
+		server := serverConfig(&servers, serverName)
+		server.StatusFile = ""
+		server.StatusVersion = 0
+		server.ManagementAddress = managementAddress
+	}
+	for _, openVPNConfig := range cfg.StatusCollector.OpenVPNConfig {
+		serverName, openVPNConfig := parseStatusFileSlice(openVPNConfig)
+		if !hasServer(servers, serverName) {
+			return nil, fmt.Errorf("--openvpn-config refers to unknown server %q", serverName)
+		}
+		serverConfig(&servers, serverName).OpenVPNConfig = openVPNConfig
+	}
+	for _, openVPNLog := range cfg.StatusCollector.OpenVPNLog {
+		serverName, openVPNLog := parseStatusFileSlice(openVPNLog)
+		if !hasServer(servers, serverName) {
+			return nil, fmt.Errorf("--openvpn-log refers to unknown server %q", serverName)
+		}
+		serverConfig(&servers, serverName).LogFile = openVPNLog
+	}
+	for i := range servers {
+		server := &servers[i]
+		if server.ManagementPassword == "" || c.IsSet("management.password") {
+			server.ManagementPassword = cfg.StatusCollector.Management.Password
+		}
+		if server.ManagementTimeout == 0 || c.IsSet("management.timeout") {
+			server.ManagementTimeout = cfg.StatusCollector.Management.Timeout
+		}
+		if server.StaleAfter == 0 || c.IsSet("status.max-age") {
+			server.StaleAfter = cfg.StatusCollector.MaxAge
+		}
+		if server.StaleAfter < 0 {
+			return nil, fmt.Errorf("server %q has a negative max age", server.Name)
+		}
+		if server.ClientMetrics == nil || c.IsSet("disable-client-metrics") {
+			clientMetrics := cfg.StatusCollector.ExportClientMetrics
+			server.ClientMetrics = &clientMetrics
+		}
+		if policy, ok := cfg.StatusCollector.DuplicatePolicies[server.Name]; ok {
+			server.DuplicatePolicy = policy
+		} else if server.DuplicatePolicy == "" || defaultPolicySet {
+			server.DuplicatePolicy = cfg.StatusCollector.DuplicatePolicy
+		}
+		if !collector.IsDuplicatePolicy(server.DuplicatePolicy) {
+			return nil, fmt.Errorf("unknown duplicate common name policy %q of server %q, must be one of %s", server.DuplicatePolicy, server.Name, strings.Join(collector.DuplicatePolicies, ", "))
+		}
+		if err := collector.ValidateLabels(server.Labels); err != nil {
+			return nil, fmt.Errorf("server %q: %v", server.Name, err)
+		}
+	}
+	return servers, nil
+}
+
+func hasServer(servers []config.ServerConfig, name string) bool {
+	for _, server := range servers {
+		if server.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// serverConfig returns the server with the given name, a new server is appended if it does not exist
+func serverConfig(servers *[]config.ServerConfig, name string) *config.ServerConfig {
+	for i := range *servers {
+		if (*servers)[i].Name == name {
+			return &(*servers)[i]
+		}
+	}
+	*servers = append(*servers, config.ServerConfig{Name: name})
+	return &(*servers)[len(*servers)-1]
+}
+
+// parseManagementAddressSlice splits name:address into name and address. As the address
+// itself contains colons (host:port or unix:/path), a missing name is detected by the
+// value being a valid address on its own.
+func parseManagementAddressSlice(managementAddress string) (string, string) {
+	if strings.HasPrefix(managementAddress, "unix:") {
+		return managementAddress, managementAddress
+	}
+	if _, _, err := net.SplitHostPort(managementAddress); err == nil {
+		return managementAddress, managementAddress
+	}
+	parts := strings.SplitN(managementAddress, ":", 2)
+	if len(parts) > 1 {
+		return parts[0], parts[1]
+	}
+	return parts[0], parts[0]
+}
+
 func setupLogging(cfg *config.Config) log.Logger {
 	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 
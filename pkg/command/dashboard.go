@@ -0,0 +1,228 @@
+package command
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/collector"
+)
+
+// dashboardServer is a server as rendered by the dashboard
+type dashboardServer struct {
+	Name      string
+	Source    string
+	UpdatedAt time.Time
+	Age       time.Duration
+	Error     string
+	Clients   []dashboardClient
+}
+
+type dashboardClient struct {
+	CommonName     string
+	RealAddress    string
+	VirtualAddress string
+	ConnectedSince time.Time
+	Duration       time.Duration
+	BytesReceived  float64
+	BytesSent      float64
+}
+
+type dashboardData struct {
+	Version     string
+	MetricsPath string
+	Sort        string
+	Order       string
+	Servers     []dashboardServer
+}
+
+// dashboardColumns are the sortable columns of the client table
+var dashboardColumns = map[string]func(a, b dashboardClient) bool{
+	"common_name":     func(a, b dashboardClient) bool { return a.CommonName < b.CommonName },
+	"real_address":    func(a, b dashboardClient) bool { return a.RealAddress < b.RealAddress },
+	"virtual_address": func(a, b dashboardClient) bool { return a.VirtualAddress < b.VirtualAddress },
+	"duration":        func(a, b dashboardClient) bool { return a.Duration < b.Duration },
+	"bytes_received":  func(a, b dashboardClient) bool { return a.BytesReceived < b.BytesReceived },
+	"bytes_sent":      func(a, b dashboardClient) bool { return a.BytesSent < b.BytesSent },
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"bytes":    formatBytes,
+	"duration": formatDuration,
+	"sortLink": func(data dashboardData, column string) string {
+		order := "asc"
+		if data.Sort == column && data.Order == "asc" {
+			order = "desc"
+		}
+		return "?sort=" + column + "&order=" + order
+	},
+	"sortMark": func(data dashboardData, column string) string {
+		switch {
+		case data.Sort != column:
+			return ""
+		case data.Order == "desc":
+			return " ▼"
+		default:
+			return " ▲"
+		}
+	},
+}).Parse(`<html>
+<head>
+<title>OpenVPN Exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th a { color: inherit; text-decoration: none; }
+td.number { text-align: right; }
+.error { color: #b00; }
+.ok { color: #070; }
+</style>
+</head>
+<body>
+<h1>OpenVPN exporter</h1>
+<p><a href="{{.MetricsPath}}">Metrics</a> &middot; version {{.Version}}</p>
+<h2>Servers</h2>
+<table>
+<tr><th>Name</th><th>Source</th><th>Last updated</th><th>Clients</th><th>Status</th></tr>
+{{range .Servers}}<tr>
+<td><a href="#server-{{.Name}}">{{.Name}}</a></td>
+<td>{{.Source}}</td>
+<td>{{if not .UpdatedAt.IsZero}}{{.UpdatedAt.Format "2006-01-02 15:04:05 MST"}} ({{duration .Age}} ago){{end}}</td>
+<td class="number">{{if not .Error}}{{len .Clients}}{{end}}</td>
+<td>{{if .Error}}<span class="error">{{.Error}}</span>{{else}}<span class="ok">ok</span>{{end}}</td>
+</tr>
+{{end}}</table>
+{{$data := .}}{{range .Servers}}{{if not .Error}}
+<h2 id="server-{{.Name}}">{{.Name}}</h2>
+<table>
+<tr>
+<th><a href="{{sortLink $data "common_name"}}">Common name{{sortMark $data "common_name"}}</a></th>
+<th><a href="{{sortLink $data "real_address"}}">Real address{{sortMark $data "real_address"}}</a></th>
+<th><a href="{{sortLink $data "virtual_address"}}">Virtual address{{sortMark $data "virtual_address"}}</a></th>
+<th><a href="{{sortLink $data "duration"}}">Connected{{sortMark $data "duration"}}</a></th>
+<th><a href="{{sortLink $data "bytes_received"}}">Received{{sortMark $data "bytes_received"}}</a></th>
+<th><a href="{{sortLink $data "bytes_sent"}}">Sent{{sortMark $data "bytes_sent"}}</a></th>
+</tr>
+{{range .Clients}}<tr>
+<td>{{.CommonName}}</td>
+<td>{{.RealAddress}}</td>
+<td>{{.VirtualAddress}}</td>
+<td title="{{.ConnectedSince.Format "2006-01-02 15:04:05 MST"}}">{{duration .Duration}}</td>
+<td class="number">{{bytes .BytesReceived}}</td>
+<td class="number">{{bytes .BytesSent}}</td>
+</tr>
+{{else}}<tr><td colspan="6">No clients connected</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// handleDashboard renders the configured servers and their connected clients, the client
+// tables are sorted by the sort and order query parameters
+func (s *servers) handleDashboard(metricsPath string, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := dashboardData{
+			Version:     version,
+			MetricsPath: metricsPath,
+			Sort:        r.URL.Query().Get("sort"),
+			Order:       r.URL.Query().Get("order"),
+		}
+		less, ok := dashboardColumns[data.Sort]
+		if !ok {
+			data.Sort = "common_name"
+			less = dashboardColumns[data.Sort]
+		}
+		if data.Order != "desc" {
+			data.Order = "asc"
+		}
+		now := time.Now()
+		for _, ovpn := range s.configured() {
+			server := newDashboardServer(ovpn, now)
+			sort.SliceStable(server.Clients, func(i, j int) bool {
+				if data.Order == "desc" {
+					return less(server.Clients[j], server.Clients[i])
+				}
+				return less(server.Clients[i], server.Clients[j])
+			})
+			data.Servers = append(data.Servers, server)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTemplate.Execute(w, data); err != nil {
+			level.Warn(s.logger).Log(
+				"msg", "error rendering dashboard",
+				"err", err,
+			)
+		}
+	}
+}
+
+func newDashboardServer(ovpn collector.OpenVPNServer, now time.Time) dashboardServer {
+	server := dashboardServer{
+		Name:   ovpn.Name,
+		Source: ovpn.StatusFile,
+	}
+	if ovpn.ManagementAddress != "" {
+		server.Source = ovpn.ManagementAddress
+	}
+	status, err := ovpn.Status()
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	server.UpdatedAt = status.UpdatedAt
+	server.Age = now.Sub(status.UpdatedAt)
+	for _, client := range status.ClientList {
+		server.Clients = append(server.Clients, dashboardClient{
+			CommonName:     client.CommonName,
+			RealAddress:    client.RealAddress,
+			VirtualAddress: strings.TrimSpace(client.VirtualAddress + " " + client.VirtualIPv6Address),
+			ConnectedSince: client.ConnectedSince,
+			Duration:       now.Sub(client.ConnectedSince),
+			BytesReceived:  client.BytesReceived,
+			BytesSent:      client.BytesSent,
+		})
+	}
+	return server
+}
+
+// formatBytes returns the amount of bytes in binary units
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
+
+// formatDuration returns the duration rounded to its two largest units, like 3d 4h or 5m 10s
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	seconds := int(d/time.Second) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
@@ -0,0 +1,122 @@
+package command
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func renderDashboard(t *testing.T, query string) string {
+	w := httptest.NewRecorder()
+	newTestServers().handleDashboard("/metrics", "1.0.0")(w, httptest.NewRequest(http.MethodGet, "/"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+	return w.Body.String()
+}
+
+func TestDashboard(t *testing.T) {
+	body := renderDashboard(t, "")
+	for _, expected := range []string{
+		`<a href="/metrics">Metrics</a>`,
+		"version 1.0.0",
+		`<h2 id="server-v2">v2</h2>`,
+		"<td>test@localhost</td>",
+		"<td>1.2.3.4</td>",
+		"<td>10.80.0.65</td>",
+		"3.8 KiB",
+		`<span class="ok">ok</span>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("dashboard does not contain %q", expected)
+		}
+	}
+}
+
+func TestDashboardErrorState(t *testing.T) {
+	body := renderDashboard(t, "")
+	if !strings.Contains(body, `<span class="error">open ../../example/missing.status: no such file or directory</span>`) {
+		t.Errorf("dashboard does not show the error of the unreadable server")
+	}
+	if strings.Contains(body, `<h2 id="server-missing">`) {
+		t.Errorf("dashboard should not render a client table for the unreadable server")
+	}
+}
+
+var dashboardSortTestCases = []struct {
+	scenarioName string
+	query        string
+	// first is the common name expected in the first row
+	first string
+	mark  string
+}{
+	{"default", "", "test1@localhost", `Common name ▲`},
+	{"unknown column", "?sort=password", "test1@localhost", `Common name ▲`},
+	{"common name descending", "?sort=common_name&order=desc", "test@localhost", `Common name ▼`},
+	{"bytes received", "?sort=bytes_received&order=asc", "test@localhost", `Received ▲`},
+	{"bytes received descending", "?sort=bytes_received&order=desc", "test1@localhost", `Received ▼`},
+	{"bytes sent", "?sort=bytes_sent", "test@localhost", `Sent ▲`},
+}
+
+func TestDashboardSorting(t *testing.T) {
+	for _, tt := range dashboardSortTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			body := renderDashboard(t, tt.query)
+			first := strings.Index(body, "<td>test1@localhost</td>")
+			second := strings.Index(body, "<td>test@localhost</td>")
+			if tt.first == "test@localhost" {
+				first, second = second, first
+			}
+			if first < 0 || second < 0 || first > second {
+				t.Errorf("expected %s in the first row", tt.first)
+			}
+			if !strings.Contains(body, tt.mark) {
+				t.Errorf("expected the sorted column to be marked with %q", tt.mark)
+			}
+		})
+	}
+}
+
+var formatBytesTestCases = []struct {
+	bytes    float64
+	expected string
+}{
+	{0, "0 B"},
+	{1023, "1023 B"},
+	{1024, "1.0 KiB"},
+	{3860, "3.8 KiB"},
+	{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+	{3 * 1024 * 1024 * 1024 * 1024 * 1024, "3072.0 TiB"},
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range formatBytesTestCases {
+		if formatted := formatBytes(tt.bytes); formatted != tt.expected {
+			t.Errorf("expected %s for %v, got %s", tt.expected, tt.bytes, formatted)
+		}
+	}
+}
+
+var formatDurationTestCases = []struct {
+	duration time.Duration
+	expected string
+}{
+	{-time.Second, "0s"},
+	{42 * time.Second, "42s"},
+	{5*time.Minute + 10*time.Second, "5m 10s"},
+	{2*time.Hour + 3*time.Minute + 4*time.Second, "2h 3m"},
+	{76 * time.Hour, "3d 4h"},
+}
+
+func TestFormatDuration(t *testing.T) {
+	for _, tt := range formatDurationTestCases {
+		if formatted := formatDuration(tt.duration); formatted != tt.expected {
+			t.Errorf("expected %s for %v, got %s", tt.expected, tt.duration, formatted)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/config"
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// discoverServers returns a server for every openvpn config file matching the glob patterns
+// which defines a status file or a management interface. Servers are named after the basename
+// of the config file, the status file takes precedence over the management interface.
+func discoverServers(logger log.Logger, patterns []string) ([]config.ServerConfig, error) {
+	var servers []config.ServerConfig
+	names := make(map[string]string)
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			if other, ok := names[name]; ok {
+				level.Warn(logger).Log(
+					"msg", "skipping discovered config with duplicate name",
+					"config", path,
+					"other", other,
+				)
+				continue
+			}
+			server, err := discoverServer(logger, name, path)
+			if err != nil {
+				level.Warn(logger).Log(
+					"msg", "error parsing discovered config",
+					"config", path,
+					"err", err,
+				)
+				continue
+			}
+			if server == nil {
+				level.Debug(logger).Log(
+					"msg", "discovered config defines neither status nor management",
+					"config", path,
+				)
+				continue
+			}
+			names[name] = path
+			servers = append(servers, *server)
+		}
+	}
+	return servers, nil
+}
+
+func discoverServer(logger log.Logger, name string, path string) (*config.ServerConfig, error) {
+	ovpnConfig, err := openvpn.ParseConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	level.Debug(logger).Log(
+		"msg", "parsed discovered config",
+		"config", path,
+		"statusFile", ovpnConfig.StatusFile,
+		"statusVersion", ovpnConfig.StatusVersion,
+		"managementAddress", ovpnConfig.ManagementAddress,
+	)
+	switch {
+	case ovpnConfig.StatusFile != "":
+		return &config.ServerConfig{
+			Name:          name,
+			StatusFile:    ovpnConfig.StatusFile,
+			StatusVersion: ovpnConfig.StatusVersion,
+			OpenVPNConfig: path,
+			LogFile:       ovpnConfig.LogFile,
+		}, nil
+	case ovpnConfig.ManagementAddress != "":
+		server := &config.ServerConfig{
+			Name:              name,
+			ManagementAddress: ovpnConfig.ManagementAddress,
+			OpenVPNConfig:     path,
+			LogFile:           ovpnConfig.LogFile,
+		}
+		if ovpnConfig.ManagementPasswordFile != "" {
+			password, err := openvpn.ReadManagementPassword(ovpnConfig.ManagementPasswordFile)
+			if err != nil {
+				return nil, err
+			}
+			server.ManagementPassword = password
+		}
+		return server, nil
+	}
+	return nil, nil
+}
+
+// watchDiscovery periodically rediscovers the servers and reloads the configuration if
+// they changed
+func (s *servers) watchDiscovery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		_ = s.refresh()
+	}
+}
@@ -0,0 +1,314 @@
+package command
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/collector"
+	"github.com/patrickjahns/openvpn_exporter/pkg/config"
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// servers maintains the collectors of the configured OpenVPN servers and replaces them
+// when the configuration is reloaded
+type servers struct {
+	mu           sync.Mutex
+	logger       log.Logger
+	cfg          *config.Config
+	load         func(log.Logger) ([]config.ServerConfig, error)
+	clientTotals *collector.ClientTotals
+	sessionChurn *collector.SessionChurn
+	statusCache  *collector.StatusCache
+	configCache  *collector.ConfigCache
+	collector    *collector.ReloadableCollector
+	trackers     map[trackerKey]*tracker
+	logTailers   map[string]*logTailer
+	states       map[stateKey]*collector.ServerState
+	current      []config.ServerConfig
+	// openVPNServers are the servers of the current collectors
+	openVPNServers []collector.OpenVPNServer
+}
+
+// trackerKey identifies a management interface connection, trackers are kept across reloads
+// as long as a server with the same connection settings is configured
+type trackerKey struct {
+	address  string
+	password string
+	timeout  time.Duration
+}
+
+// stateKey identifies the source of a server, the collection errors and the last complete
+// status are kept across reloads as long as a server with the same source is configured
+type stateKey struct {
+	name              string
+	statusFile        string
+	managementAddress string
+}
+
+type tracker struct {
+	*openvpn.ClientTracker
+	cancel context.CancelFunc
+}
+
+// logTailer follows a log file, log tailers are kept across reloads as long as a server with
+// the same log file is configured
+type logTailer struct {
+	*openvpn.LogTailer
+	cancel context.CancelFunc
+}
+
+// logTailInterval is the interval in which log files are checked for new lines
+const logTailInterval = time.Second
+
+func newServers(logger log.Logger, cfg *config.Config, load func(log.Logger) ([]config.ServerConfig, error), clientTotals *collector.ClientTotals, sessionChurn *collector.SessionChurn) *servers {
+	s := &servers{
+		logger:       logger,
+		cfg:          cfg,
+		load:         load,
+		clientTotals: clientTotals,
+		sessionChurn: sessionChurn,
+		statusCache:  collector.NewStatusCache(),
+		configCache:  collector.NewConfigCache(),
+		collector:    collector.NewReloadableCollector(),
+		trackers:     make(map[trackerKey]*tracker),
+		logTailers:   make(map[string]*logTailer),
+		states:       make(map[stateKey]*collector.ServerState),
+	}
+	s.current = cfg.StatusCollector.Servers
+	s.collector.Reload(s.collectors(s.current)...)
+	return s
+}
+
+// reload loads the configuration and atomically replaces the collectors, the current
+// collectors are kept if the configuration is invalid
+func (s *servers) reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serverConfigs, err := s.load(s.logger)
+	if err != nil {
+		return s.reloadFailed(err)
+	}
+	s.apply(serverConfigs)
+	return nil
+}
+
+// refresh reloads the configuration only if the servers changed
+func (s *servers) refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serverConfigs, err := s.load(s.logger)
+	if err != nil {
+		return s.reloadFailed(err)
+	}
+	if !reflect.DeepEqual(serverConfigs, s.current) {
+		s.apply(serverConfigs)
+	}
+	return nil
+}
+
+// apply atomically replaces the collectors with the collectors of the loaded servers
+func (s *servers) apply(serverConfigs []config.ServerConfig) {
+	s.current = serverConfigs
+	s.collector.Reload(s.collectors(serverConfigs)...)
+	level.Info(s.logger).Log(
+		"msg", "configuration reloaded",
+		"servers", len(serverConfigs),
+	)
+}
+
+func (s *servers) reloadFailed(err error) error {
+	level.Error(s.logger).Log(
+		"msg", "error reloading configuration",
+		"err", err,
+	)
+	s.collector.ReloadFailed()
+	return err
+}
+
+// collectors returns the collectors for the servers, trackers of servers which are no longer
+// configured are stopped and their state is dropped
+func (s *servers) collectors(serverConfigs []config.ServerConfig) []prometheus.Collector {
+	var openVPServers []collector.OpenVPNServer
+	trackers := make(map[trackerKey]*tracker)
+	logTailers := make(map[string]*logTailer)
+	states := make(map[stateKey]*collector.ServerState)
+	collectClientMetrics := false
+	for _, serverConfig := range serverConfigs {
+		level.Info(s.logger).Log(
+			"msg", "registering collector for",
+			"serverName", serverConfig.Name,
+			"statusFile", serverConfig.StatusFile,
+			"managementAddress", serverConfig.ManagementAddress,
+		)
+		server := collector.OpenVPNServer{
+			Name:                 serverConfig.Name,
+			StatusFile:           serverConfig.StatusFile,
+			StatusVersion:        serverConfig.StatusVersion,
+			ParseError:           0,
+			ManagementAddress:    serverConfig.ManagementAddress,
+			ManagementPassword:   serverConfig.ManagementPassword,
+			ManagementTimeout:    serverConfig.ManagementTimeout,
+			DuplicatePolicy:      serverConfig.DuplicatePolicy,
+			DisableClientMetrics: !*serverConfig.ClientMetrics,
+			Labels:               serverConfig.Labels,
+			StaleAfter:           serverConfig.StaleAfter,
+			ConfigFile:           serverConfig.OpenVPNConfig,
+			StatusCache:          s.statusCache,
+			ConfigCache:          s.configCache,
+		}
+		key := stateKey{server.Name, server.StatusFile, server.ManagementAddress}
+		state, ok := s.states[key]
+		if !ok {
+			state = collector.NewServerState()
+		}
+		states[key] = state
+		server.State = state
+		collectClientMetrics = collectClientMetrics || *serverConfig.ClientMetrics
+		if server.ManagementAddress != "" && s.cfg.StatusCollector.Management.Events {
+			key := trackerKey{server.ManagementAddress, server.ManagementPassword, server.ManagementTimeout}
+			t, ok := s.trackers[key]
+			if !ok {
+				t = s.startTracker(key)
+			}
+			trackers[key] = t
+			server.Tracker = t.ClientTracker
+		}
+		if serverConfig.LogFile != "" {
+			t, ok := s.logTailers[serverConfig.LogFile]
+			if !ok {
+				t = s.startLogTailer(serverConfig.LogFile)
+			}
+			logTailers[serverConfig.LogFile] = t
+			server.LogTailer = t.LogTailer
+		}
+		openVPServers = append(openVPServers, server)
+	}
+	for key, t := range s.trackers {
+		if _, ok := trackers[key]; !ok {
+			t.cancel()
+		}
+	}
+	s.trackers = trackers
+	s.openVPNServers = openVPServers
+	s.statusCache.Retain(openVPServers)
+	s.configCache.Retain(openVPServers)
+	for path, t := range s.logTailers {
+		if _, ok := logTailers[path]; !ok {
+			t.cancel()
+		}
+	}
+	s.logTailers = logTailers
+	s.states = states
+
+	collectors := []prometheus.Collector{
+		collector.NewOpenVPNCollector(
+			s.logger,
+			openVPServers,
+			collectClientMetrics,
+			s.cfg.StatusCollector.ExportClientInfo,
+			s.cfg.StatusCollector.ExportProtocols,
+			s.clientTotals,
+			s.sessionChurn,
+		),
+	}
+	if s.cfg.StatusCollector.Management.Events {
+		collectors = append(collectors, collector.NewPeerInfoCollector(
+			s.logger,
+			openVPServers,
+			collectClientMetrics && s.cfg.StatusCollector.ExportPeerInfo,
+		))
+	}
+	if len(logTailers) > 0 {
+		collectors = append(collectors, collector.NewLogCollector(s.logger, openVPServers))
+	}
+	if s.cfg.StatusCollector.Certificates.Enabled || len(s.cfg.StatusCollector.Certificates.Files) > 0 {
+		var certificateServers []collector.OpenVPNServer
+		if s.cfg.StatusCollector.Certificates.Enabled {
+			certificateServers = openVPServers
+		}
+		collectors = append(collectors, collector.NewCertificateCollector(
+			s.logger,
+			certificateServers,
+			s.cfg.StatusCollector.Certificates.Files,
+		))
+	}
+	if len(s.cfg.StatusCollector.PKIIndex) > 0 {
+		var pki []collector.PKI
+		for _, index := range s.cfg.StatusCollector.PKIIndex {
+			name, indexFile := parseStatusFileSlice(index)
+			pki = append(pki, collector.PKI{Name: name, IndexFile: indexFile})
+		}
+		collectors = append(collectors, collector.NewPKICollector(s.logger, pki))
+	}
+	return collectors
+}
+
+// configured returns the currently configured servers
+func (s *servers) configured() []collector.OpenVPNServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openVPNServers
+}
+
+func (s *servers) startTracker(key trackerKey) *tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &tracker{
+		ClientTracker: openvpn.NewClientTracker(
+			key.address,
+			key.password,
+			key.timeout,
+			s.cfg.StatusCollector.Management.BytecountInterval,
+		),
+		cancel: cancel,
+	}
+	go t.Run(ctx)
+	return t
+}
+
+func (s *servers) startLogTailer(path string) *logTailer {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &logTailer{
+		LogTailer: openvpn.NewLogTailer(path, logTailInterval),
+		cancel:    cancel,
+	}
+	go t.Run(ctx)
+	return t
+}
+
+// handleReload reloads the configuration on POST requests
+func (s *servers) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.reload(); err != nil {
+		http.Error(w, "failed to reload configuration: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write([]byte("configuration reloaded\n"))
+}
+
+// reloadSignals returns a channel receiving SIGHUP
+func reloadSignals() chan os.Signal {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	return hup
+}
+
+// watchSignals reloads the configuration on every signal received from hup
+func (s *servers) watchSignals(hup <-chan os.Signal) {
+	for range hup {
+		_ = s.reload()
+	}
+}
@@ -0,0 +1,243 @@
+package command
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/collector"
+	"github.com/patrickjahns/openvpn_exporter/pkg/config"
+)
+
+// metricValues gathers the metrics of c and returns the values of the metric name by the
+// server and reason labels
+func metricValues(t *testing.T, c prometheus.Collector, name string) map[string]float64 {
+	r := prometheus.NewRegistry()
+	r.MustRegister(c)
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatalf("gathering failed: %v", err)
+	}
+	result := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			var server, reason string
+			for _, label := range metric.GetLabel() {
+				switch label.GetName() {
+				case "server":
+					server = label.GetValue()
+				case "reason":
+					reason = "/" + label.GetValue()
+				}
+			}
+			result[server+reason] = metricValue(metric)
+		}
+	}
+	return result
+}
+
+func metricValue(metric *dto.Metric) float64 {
+	if metric.GetCounter() != nil {
+		return metric.GetCounter().GetValue()
+	}
+	return metric.GetGauge().GetValue()
+}
+
+// reloadTestServers returns servers reading the status file statusFile and a missing status
+// file, the servers are loaded from *serverConfigs on reloads
+func reloadTestServers(statusFile string, serverConfigs *[]config.ServerConfig, loadErr *error) *servers {
+	clientMetrics := true
+	*serverConfigs = []config.ServerConfig{
+		{Name: "v2", StatusFile: statusFile, ClientMetrics: &clientMetrics},
+		{Name: "missing", StatusFile: "../../example/missing.status", ClientMetrics: &clientMetrics},
+	}
+	cfg := &config.Config{}
+	cfg.StatusCollector.Servers = *serverConfigs
+	load := func(log.Logger) ([]config.ServerConfig, error) {
+		return *serverConfigs, *loadErr
+	}
+	return newServers(log.NewNopLogger(), cfg, load, nil, nil)
+}
+
+func reload(s *servers) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.handleReload(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	return w
+}
+
+func TestHandleReload(t *testing.T) {
+	var serverConfigs []config.ServerConfig
+	var loadErr error
+	s := reloadTestServers("../../example/version2.status", &serverConfigs, &loadErr)
+
+	w := httptest.NewRecorder()
+	s.handleReload(w, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("expected status 405 allowing POST, got %d allowing %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	serverConfigs = serverConfigs[:1]
+	if w := reload(s); w.Code != http.StatusOK || w.Body.String() != "configuration reloaded\n" {
+		t.Fatalf("expected a successful reload, got %d: %s", w.Code, w.Body.String())
+	}
+	if up := metricValues(t, s.collector, "openvpn_up"); len(up) != 1 || up["v2"] != 1 {
+		t.Errorf("expected only the reloaded server, got %v", up)
+	}
+	if len(s.configured()) != 1 {
+		t.Errorf("expected the configured servers to be replaced, got %v", s.configured())
+	}
+
+	loadErr = errors.New("invalid configuration")
+	serverConfigs = nil
+	if w := reload(s); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "invalid configuration") {
+		t.Errorf("expected a failed reload, got %d: %s", w.Code, w.Body.String())
+	}
+	if successful := metricValues(t, s.collector, "openvpn_exporter_config_last_reload_successful"); successful[""] != 0 {
+		t.Errorf("expected the failed reload to be reported, got %v", successful)
+	}
+	if up := metricValues(t, s.collector, "openvpn_up"); len(up) != 1 || up["v2"] != 1 {
+		t.Errorf("expected the collectors to be kept on a failed reload, got %v", up)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	var serverConfigs []config.ServerConfig
+	var loadErr error
+	s := reloadTestServers("../../example/version2.status", &serverConfigs, &loadErr)
+	loads := 0
+	load := s.load
+	s.load = func(logger log.Logger) ([]config.ServerConfig, error) {
+		loads++
+		return load(logger)
+	}
+
+	configured := s.configured()
+	if err := s.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 1 || &s.configured()[0] != &configured[0] {
+		t.Errorf("expected an unchanged configuration to be loaded once and kept, loaded %d times", loads)
+	}
+
+	serverConfigs = serverConfigs[:1]
+	if err := s.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 2 || len(s.configured()) != 1 {
+		t.Errorf("expected a changed configuration to be loaded once and applied, loaded %d times", loads-1)
+	}
+
+	loadErr = errors.New("invalid configuration")
+	if err := s.refresh(); err == nil || loads != 3 {
+		t.Errorf("expected an invalid configuration to fail the refresh, loaded %d times", loads-2)
+	}
+	if successful := metricValues(t, s.collector, "openvpn_exporter_config_last_reload_successful"); successful[""] != 0 {
+		t.Errorf("expected the failed refresh to be reported, got %v", successful)
+	}
+}
+
+func TestReloadKeepsCollectorState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	status, err := ioutil.ReadFile("../../example/version2.status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusFile := filepath.Join(dir, "server.status")
+	if err := ioutil.WriteFile(statusFile, status, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var serverConfigs []config.ServerConfig
+	var loadErr error
+	s := reloadTestServers(statusFile, &serverConfigs, &loadErr)
+
+	collectionErrors := metricValues(t, s.collector, "openvpn_collection_error")
+	if len(collectionErrors) != 1 || collectionErrors["missing/"+collector.ReasonFileNotFound] != 1 {
+		t.Fatalf("unexpected collection errors: %v", collectionErrors)
+	}
+
+	// the file is rewritten between the scrapes, the server falls back to the status of the
+	// collector before the reload
+	truncated := status[:strings.Index(string(status), "END")]
+	if err := ioutil.WriteFile(statusFile, truncated, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if w := reload(s); w.Code != http.StatusOK {
+		t.Fatalf("expected a successful reload, got %d: %s", w.Code, w.Body.String())
+	}
+	collectionErrors = metricValues(t, s.collector, "openvpn_collection_error")
+	if collectionErrors["missing/"+collector.ReasonFileNotFound] != 2 {
+		t.Errorf("collection errors are reset by the reload: %v", collectionErrors)
+	}
+	if collectionErrors["v2/"+collector.ReasonTruncated] != 1 {
+		t.Errorf("expected the truncated status to be counted: %v", collectionErrors)
+	}
+	if fallback := metricValues(t, s.collector, "openvpn_status_fallback"); fallback["v2"] != 1 {
+		t.Errorf("expected the last complete status before the reload to be used, got %v", fallback)
+	}
+
+	// the state of a server which is no longer configured is dropped
+	configured := serverConfigs
+	serverConfigs = configured[:1]
+	reload(s)
+	serverConfigs = configured
+	reload(s)
+	collectionErrors = metricValues(t, s.collector, "openvpn_collection_error")
+	if collectionErrors["missing/"+collector.ReasonFileNotFound] != 1 {
+		t.Errorf("expected the collection errors of the removed server to start again, got %v", collectionErrors)
+	}
+}
+
+func TestWatchSignals(t *testing.T) {
+	var serverConfigs []config.ServerConfig
+	var loadErr error
+	s := reloadTestServers("../../example/version2.status", &serverConfigs, &loadErr)
+	serverConfigs = serverConfigs[:1]
+
+	hup := make(chan os.Signal, 1)
+	go s.watchSignals(hup)
+	defer close(hup)
+	hup <- syscall.SIGHUP
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.configured()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("configuration was not reloaded on SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloadSignals(t *testing.T) {
+	hup := reloadSignals()
+	defer signal.Stop(hup)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sig := <-hup:
+		if sig != syscall.SIGHUP {
+			t.Errorf("expected SIGHUP, got %v", sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("SIGHUP was not received")
+	}
+}
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+	"github.com/patrickjahns/openvpn_exporter/pkg/webhook"
+)
+
+// watchSessions periodically compares the status of the servers with the previous status and
+// notifies the webhooks of connected and disconnected clients. Clients connected when a server
+// is seen for the first time are not notified.
+func (s *servers) watchSessions(notifier *webhook.Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	previous := make(map[string][]openvpn.Client)
+	for {
+		configured := make(map[string][]openvpn.Client)
+		for _, ovpn := range s.configured() {
+			status, err := ovpn.Status()
+			if err != nil {
+				// errors are already reported by the OpenVPNCollector, the previous clients
+				// are kept to not notify all clients again once the status is available
+				level.Debug(s.logger).Log(
+					"msg", "skipping webhook events",
+					"name", ovpn.Name,
+					"err", err,
+				)
+				if clients, ok := previous[ovpn.Name]; ok {
+					configured[ovpn.Name] = clients
+				}
+				continue
+			}
+			configured[ovpn.Name] = status.ClientList
+			clients, ok := previous[ovpn.Name]
+			if !ok {
+				continue
+			}
+			now := time.Now()
+			connected, disconnected := openvpn.DiffSessions(clients, status.ClientList)
+			for _, client := range disconnected {
+				notifier.Notify(webhook.NewEvent(webhook.EventDisconnect, ovpn.Name, client, now))
+			}
+			for _, client := range connected {
+				notifier.Notify(webhook.NewEvent(webhook.EventConnect, ovpn.Name, client, now))
+			}
+		}
+		previous = configured
+		<-ticker.C
+	}
+}
@@ -1,10 +1,16 @@
 package config
 
+import "time"
+
 // Server defines the general server configuration.
 type Server struct {
 	Addr string
 	Path string
 	Root string
+	// EnableAPI serves the parsed status of the servers as JSON below /api/v1
+	EnableAPI bool
+	// EnableDashboard serves a dashboard of the servers and their clients on the root path
+	EnableDashboard bool
 }
 
 // Logs defines the level for configuration
@@ -14,6 +20,8 @@ type Logs struct {
 
 // Config defines the general configuration object
 type Config struct {
+	// File is the path of the optional YAML configuration file
+	File            string
 	Server          Server
 	Logs            Logs
 	StatusCollector StatusCollector
@@ -23,7 +31,66 @@ type Config struct {
 // StatusCollector contains configuration for the OpenVPN status collector
 type StatusCollector struct {
 	ExportClientMetrics bool
+	ExportClientInfo    bool
+	ExportProtocols     bool
+	ExportPeerInfo      bool
 	StatusFile          []string
+	// MaxAge is the default duration after which the status of a server is stale
+	MaxAge time.Duration
+	// OpenVPNConfig contains the OpenVPN config files of servers as name:path
+	OpenVPNConfig []string
+	// OpenVPNLog contains the OpenVPN log files of servers as name:path
+	OpenVPNLog []string
+	// Servers contains the servers of the configuration file merged with the flags
+	Servers      []ServerConfig
+	Management   Management
+	ClientTotals ClientTotals
+	Discovery    Discovery
+	Certificates Certificates
+	Webhook      Webhook
+	// PKIIndex contains the easy-rsa index.txt databases as name:path
+	PKIIndex []string
+	// DuplicatePolicy is the default duplicate common name policy
+	DuplicatePolicy string
+	// DuplicatePolicies contains the duplicate common name policy per server name
+	DuplicatePolicies map[string]string
+}
+
+// ClientTotals contains configuration for the persistent per common name traffic counters
+type ClientTotals struct {
+	Enabled   bool
+	StateFile string
+}
+
+// Certificates contains configuration for the certificate and CRL expiry collector
+type Certificates struct {
+	Enabled bool
+	Files   []string
+}
+
+// Webhook contains configuration for posting client connect and disconnect events
+type Webhook struct {
+	URLs      []string
+	Interval  time.Duration
+	QueueSize int
+	Retries   int
+	Timeout   time.Duration
+}
+
+// Discovery contains configuration for discovering OpenVPN instances from their config files
+type Discovery struct {
+	Enabled  bool
+	Globs    []string
+	Interval time.Duration
+}
+
+// Management contains configuration for querying the OpenVPN management interface
+type Management struct {
+	Address           []string
+	Password          string
+	Timeout           time.Duration
+	Events            bool
+	BytecountInterval time.Duration
 }
 
 // Load initializes a default configuration struct.
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// File defines the structure of the YAML configuration file
+type File struct {
+	Servers []ServerConfig `yaml:"servers"`
+}
+
+// ServerConfig defines a single OpenVPN server to export, either from a status file
+// or from a management interface
+type ServerConfig struct {
+	Name               string        `yaml:"name"`
+	StatusFile         string        `yaml:"status_file"`
+	ManagementAddress  string        `yaml:"management_address"`
+	ManagementPassword string        `yaml:"management_password"`
+	ManagementTimeout  time.Duration `yaml:"management_timeout"`
+	// ClientMetrics enables the per client metrics of the server, unset means the global default
+	ClientMetrics   *bool  `yaml:"client_metrics"`
+	DuplicatePolicy string `yaml:"duplicate_cn_policy"`
+	// Labels are static labels added to all metrics of the server
+	Labels map[string]string `yaml:"labels"`
+	// StaleAfter marks the status as stale if it has not been updated for the duration
+	StaleAfter time.Duration `yaml:"stale_after"`
+	// StatusVersion is the status-version of the status file, any format is accepted if unset
+	StatusVersion int `yaml:"status_version"`
+	// OpenVPNConfig is the config file of the OpenVPN instance to export the pool capacity from
+	OpenVPNConfig string `yaml:"openvpn_config"`
+	// LogFile is the log file of the OpenVPN instance to count failures and disconnects from
+	LogFile string `yaml:"log_file"`
+}
+
+// LoadFile reads and validates the YAML configuration file
+func LoadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("validating %s: %v", path, err)
+	}
+	return file, nil
+}
+
+func (f *File) validate() error {
+	names := make(map[string]bool, len(f.Servers))
+	for i, server := range f.Servers {
+		if server.Name == "" {
+			return fmt.Errorf("server %d has no name", i+1)
+		}
+		if names[server.Name] {
+			return fmt.Errorf("server %q is defined more than once", server.Name)
+		}
+		names[server.Name] = true
+		if (server.StatusFile == "") == (server.ManagementAddress == "") {
+			return fmt.Errorf("server %q requires either status_file or management_address", server.Name)
+		}
+		if server.StatusVersion < 0 || server.StatusVersion > 3 {
+			return fmt.Errorf("server %q has an unknown status_version %d, must be 1, 2 or 3", server.Name, server.StatusVersion)
+		}
+		if server.StaleAfter < 0 {
+			return fmt.Errorf("server %q has a negative stale_after", server.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(file.Name()) })
+	_, _ = file.WriteString(content)
+	file.Close()
+	return file.Name()
+}
+
+func TestLoadFile(t *testing.T) {
+	file, err := LoadFile("../../example/config.yml")
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if len(file.Servers) != 4 {
+		t.Fatalf("Servers are not parsed correctly")
+	}
+	v3 := file.Servers[1]
+	if v3.Name != "v3" || v3.StatusFile != "./example/version3.status" || v3.ManagementAddress != "" {
+		t.Errorf("server is not parsed correctly: %+v", v3)
+	}
+	if v3.ClientMetrics == nil || *v3.ClientMetrics || file.Servers[0].ClientMetrics != nil {
+		t.Errorf("client metrics toggle is not parsed correctly")
+	}
+	if len(v3.Labels) != 2 || v3.Labels["site"] != "ams1" {
+		t.Errorf("labels are not parsed correctly: %v", v3.Labels)
+	}
+	if file.Servers[2].DuplicatePolicy != "split" {
+		t.Errorf("duplicate policy is not parsed correctly")
+	}
+	if file.Servers[3].StaleAfter != 5*time.Minute {
+		t.Errorf("stale after is not parsed correctly: %v", file.Servers[3].StaleAfter)
+	}
+}
+
+var invalidFileTestCases = []struct {
+	scenarioName string
+	content      string
+}{
+	{"missing name", "servers:\n  - status_file: a.status\n"},
+	{"duplicate name", "servers:\n  - name: a\n    status_file: a.status\n  - name: a\n    status_file: b.status\n"},
+	{"missing source", "servers:\n  - name: a\n"},
+	{"both sources", "servers:\n  - name: a\n    status_file: a.status\n    management_address: 127.0.0.1:7505\n"},
+	{"negative stale after", "servers:\n  - name: a\n    status_file: a.status\n    stale_after: -1m\n"},
+	{"unknown status version", "servers:\n  - name: a\n    status_file: a.status\n    status_version: 4\n"},
+	{"unknown field", "servers:\n  - name: a\n    statusfile: a.status\n"},
+	{"invalid duration", "servers:\n  - name: a\n    status_file: a.status\n    stale_after: soon\n"},
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	for _, tt := range invalidFileTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			if _, err := LoadFile(writeFile(t, tt.content)); err == nil {
+				t.Errorf("should have failed")
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := LoadFile("../../example/missing.yml"); err == nil {
+		t.Errorf("should have failed on a missing file")
+	}
+}
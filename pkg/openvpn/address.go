@@ -0,0 +1,76 @@
+package openvpn
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// realAddress reflects a real address as reported by openvpn
+type realAddress struct {
+	Protocol string
+	Host     string
+	Port     string
+}
+
+var protocols = []string{"udp", "udp4", "udp6", "tcp", "tcp4", "tcp6"}
+
+// parseAddress parses openvpn real addresses like 1.2.3.4:1194, ::ffff:1.2.3.4,
+// [2001:db8::1]:1194 or udp4:1.2.3.4:1194 into protocol, host and port.
+// IPv4-mapped IPv6 addresses are reported as IPv4 address.
+func parseAddress(address string) realAddress {
+	var a realAddress
+	if i := strings.Index(address, ":"); i > 0 && isProtocol(address[:i]) {
+		a.Protocol = strings.TrimSuffix(strings.TrimSuffix(address[:i], "-server"), "-client")
+		address = address[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(address, "["):
+		a.Host, a.Port, _ = net.SplitHostPort(address)
+		if a.Host == "" {
+			a.Host = strings.Trim(address, "[]")
+		}
+	case a.Protocol != "":
+		// with a protocol prefix openvpn always appends the port
+		a.Host, a.Port = splitPort(address)
+	case net.ParseIP(address) != nil:
+		a.Host = address
+	default:
+		a.Host, a.Port = splitPort(address)
+	}
+	if ip := net.ParseIP(a.Host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			a.Host = ip4.String()
+			if a.Protocol == "udp" || a.Protocol == "tcp" {
+				a.Protocol += "4"
+			}
+		} else {
+			a.Host = ip.String()
+			if a.Protocol == "udp" || a.Protocol == "tcp" {
+				a.Protocol += "6"
+			}
+		}
+	}
+	if _, err := strconv.ParseUint(a.Port, 10, 16); err != nil {
+		a.Port = ""
+	}
+	return a
+}
+
+func splitPort(address string) (string, string) {
+	i := strings.LastIndex(address, ":")
+	if i < 0 {
+		return address, ""
+	}
+	return address[:i], address[i+1:]
+}
+
+func isProtocol(prefix string) bool {
+	prefix = strings.TrimSuffix(strings.TrimSuffix(prefix, "-server"), "-client")
+	for _, protocol := range protocols {
+		if prefix == protocol {
+			return true
+		}
+	}
+	return false
+}
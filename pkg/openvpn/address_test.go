@@ -0,0 +1,40 @@
+package openvpn
+
+import "testing"
+
+var addressTestCases = []struct {
+	scenarioName string
+	address      string
+	protocol     string
+	host         string
+	port         string
+}{
+	{"ipv4 with port", "1.2.3.4:60102", "", "1.2.3.4", "60102"},
+	{"ipv4 without port", "1.2.3.4", "", "1.2.3.4", ""},
+	{"ipv4-mapped ipv6", "::ffff:1.1.1.1", "", "1.1.1.1", ""},
+	{"ipv4-mapped ipv6 with port", "::ffff:1.1.1.1:1194", "", "1.1.1.1", "1194"},
+	{"ipv6", "2001:db8::1", "", "2001:db8::1", ""},
+	{"bracketed ipv6 with port", "[2001:db8::1]:1194", "", "2001:db8::1", "1194"},
+	{"udp4 prefix", "udp4:1.2.3.4:1194", "udp4", "1.2.3.4", "1194"},
+	{"tcp4-server prefix", "tcp4-server:1.2.3.4:50123", "tcp4", "1.2.3.4", "50123"},
+	{"udp6 prefix", "udp6:2001:db8::1:1194", "udp6", "2001:db8::1", "1194"},
+	{"family derived from host", "tcp-server:[2001:db8::1]:1194", "tcp6", "2001:db8::1", "1194"},
+	{"invalid port", "1.2.3.7:fooo", "", "1.2.3.7", ""},
+}
+
+func TestParseAddress(t *testing.T) {
+	for _, tt := range addressTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			a := parseAddress(tt.address)
+			if a.Protocol != tt.protocol {
+				t.Errorf("expected protocol %q, got %q", tt.protocol, a.Protocol)
+			}
+			if a.Host != tt.host {
+				t.Errorf("expected host %q, got %q", tt.host, a.Host)
+			}
+			if a.Port != tt.port {
+				t.Errorf("expected port %q, got %q", tt.port, a.Port)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package openvpn
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config reflects the directives of an openvpn config file which are relevant for exporting
+// the status of the instance. Relative paths are resolved against the directory of the config
+// file, or the directory of the cd directive.
+type Config struct {
+	StatusFile    string
+	StatusVersion int
+	// ManagementAddress is either host:port or unix:/path/to/socket
+	ManagementAddress      string
+	ManagementPasswordFile string
+	// Topology is net30, p2p or subnet
+	Topology   string
+	Server     *net.IPNet
+	ServerIPv6 *net.IPNet
+	// ServerNoPool is set if the server directive does not define an address pool
+	ServerNoPool        bool
+	IfconfigPoolStart   net.IP
+	IfconfigPoolEnd     net.IP
+	MaxClients          int
+	IfconfigPoolPersist string
+	CA                  string
+	Cert                string
+	// CRLVerify is empty if the CRL is verified from a directory
+	CRLVerify string
+	// LogFile is the file of the log or log-append directive
+	LogFile string
+	// Inline contains the content of inline files like <ca> by tag
+	Inline map[string]string
+}
+
+type configError struct {
+	s string
+}
+
+func (e *configError) Error() string {
+	return e.s
+}
+
+// ParseConfigFile parses the openvpn config file at path
+func ParseConfigFile(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseConfig(file, filepath.Dir(path))
+}
+
+func parseConfig(reader io.Reader, dir string) (*Config, error) {
+	config := &Config{
+		StatusVersion: 1,
+		Topology:      topologyNet30,
+		MaxClients:    defaultMaxClients,
+		Inline:        make(map[string]string),
+	}
+	var statusFile, managementPasswordFile, ifconfigPoolPersist, ca, cert, crlVerify, logFile string
+	scanner := bufio.NewScanner(reader)
+	inlineTag := ""
+	var inline strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inlineTag != "" {
+			if line == "</"+inlineTag+">" {
+				config.Inline[inlineTag] = inline.String()
+				inline.Reset()
+				inlineTag = ""
+			} else {
+				inline.WriteString(line + "\n")
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") && !strings.HasPrefix(line, "</") {
+			inlineTag = strings.Trim(line, "<>")
+			continue
+		}
+		fields := splitConfigLine(line)
+		if len(fields) == 0 {
+			continue
+		}
+		directive := strings.TrimPrefix(fields[0], "--")
+		args := fields[1:]
+		switch directive {
+		case "cd":
+			if len(args) > 0 {
+				dir = resolvePath(dir, args[0])
+			}
+		case "status":
+			if len(args) == 0 {
+				return nil, &configError{"status directive requires a file"}
+			}
+			statusFile = args[0]
+		case "status-version":
+			if len(args) == 0 {
+				return nil, &configError{"status-version directive requires a version"}
+			}
+			version, err := strconv.Atoi(args[0])
+			if err != nil || version < 1 || version > 3 {
+				return nil, &configError{"bad status-version " + args[0]}
+			}
+			config.StatusVersion = version
+		case "management":
+			if len(args) < 2 {
+				return nil, &configError{"management directive requires an address and a port"}
+			}
+			if args[1] == "unix" {
+				config.ManagementAddress = unixPrefix + args[0]
+			} else {
+				config.ManagementAddress = net.JoinHostPort(args[0], args[1])
+			}
+			managementPasswordFile = ""
+			if len(args) > 2 && args[2] != "stdin" {
+				managementPasswordFile = args[2]
+			}
+		case "topology":
+			if len(args) == 0 || (args[0] != topologyNet30 && args[0] != topologyP2P && args[0] != topologySubnet) {
+				return nil, &configError{"bad topology"}
+			}
+			config.Topology = args[0]
+		case "server":
+			if len(args) < 2 {
+				return nil, &configError{"server directive requires a network and a netmask"}
+			}
+			network := net.ParseIP(args[0]).To4()
+			netmask := net.ParseIP(args[1]).To4()
+			if network == nil || netmask == nil {
+				return nil, &configError{"bad server network " + args[0] + " " + args[1]}
+			}
+			config.Server = &net.IPNet{IP: network.Mask(net.IPMask(netmask)), Mask: net.IPMask(netmask)}
+			config.ServerNoPool = len(args) > 2 && args[2] == "nopool"
+		case "server-ipv6":
+			if len(args) == 0 {
+				return nil, &configError{"server-ipv6 directive requires a network"}
+			}
+			_, network, err := net.ParseCIDR(args[0])
+			if err != nil {
+				return nil, &configError{"bad server-ipv6 network " + args[0]}
+			}
+			config.ServerIPv6 = network
+		case "ifconfig-pool":
+			if len(args) < 2 {
+				return nil, &configError{"ifconfig-pool directive requires a start and an end address"}
+			}
+			config.IfconfigPoolStart = net.ParseIP(args[0]).To4()
+			config.IfconfigPoolEnd = net.ParseIP(args[1]).To4()
+			if config.IfconfigPoolStart == nil || config.IfconfigPoolEnd == nil {
+				return nil, &configError{"bad ifconfig-pool " + args[0] + " " + args[1]}
+			}
+		case "max-clients":
+			if len(args) == 0 {
+				return nil, &configError{"max-clients directive requires a number"}
+			}
+			maxClients, err := strconv.Atoi(args[0])
+			if err != nil || maxClients < 1 {
+				return nil, &configError{"bad max-clients " + args[0]}
+			}
+			config.MaxClients = maxClients
+		case "ifconfig-pool-persist":
+			if len(args) == 0 {
+				return nil, &configError{"ifconfig-pool-persist directive requires a file"}
+			}
+			ifconfigPoolPersist = args[0]
+		case "ca":
+			if len(args) > 0 && args[0] != "[[inline]]" {
+				ca = args[0]
+			}
+		case "cert":
+			if len(args) > 0 && args[0] != "[[inline]]" {
+				cert = args[0]
+			}
+		case "crl-verify":
+			if len(args) == 0 {
+				return nil, &configError{"crl-verify directive requires a file"}
+			}
+			crlVerify = ""
+			if len(args) == 1 || args[1] != "dir" {
+				crlVerify = args[0]
+			}
+		case "log", "log-append":
+			if len(args) == 0 {
+				return nil, &configError{directive + " directive requires a file"}
+			}
+			logFile = args[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if statusFile != "" {
+		config.StatusFile = resolvePath(dir, statusFile)
+	}
+	if strings.HasPrefix(config.ManagementAddress, unixPrefix) {
+		config.ManagementAddress = unixPrefix + resolvePath(dir, strings.TrimPrefix(config.ManagementAddress, unixPrefix))
+	}
+	if managementPasswordFile != "" {
+		config.ManagementPasswordFile = resolvePath(dir, managementPasswordFile)
+	}
+	if ifconfigPoolPersist != "" {
+		config.IfconfigPoolPersist = resolvePath(dir, ifconfigPoolPersist)
+	}
+	if ca != "" {
+		config.CA = resolvePath(dir, ca)
+	}
+	if cert != "" {
+		config.Cert = resolvePath(dir, cert)
+	}
+	if crlVerify != "" {
+		config.CRLVerify = resolvePath(dir, crlVerify)
+	}
+	if logFile != "" {
+		config.LogFile = resolvePath(dir, logFile)
+	}
+	return config, nil
+}
+
+// splitConfigLine splits a config line into its directive and arguments, honoring quotes,
+// backslash escapes and comments
+func splitConfigLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	var quote rune
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			inField = true
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				field.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			inField = true
+			quote = c
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		case (c == '#' || c == ';') && !inField:
+			return fields
+		default:
+			inField = true
+			field.WriteRune(c)
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+func resolvePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// ReadManagementPassword reads the management interface password from the first line of
+// the password file
+func ReadManagementPassword(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	password, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
@@ -0,0 +1,109 @@
+package openvpn
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	config, err := ParseConfigFile("../../example/server.conf")
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if config.StatusFile != "../../example/openvpn-status.log" || config.StatusVersion != 2 {
+		t.Errorf("status is not parsed correctly: %+v", config)
+	}
+	if config.ManagementAddress != "unix:/run/openvpn/server.sock" {
+		t.Errorf("management address is not parsed correctly: %s", config.ManagementAddress)
+	}
+	if config.ManagementPasswordFile != "../../example/management-password.txt" {
+		t.Errorf("management password file is not parsed correctly: %s", config.ManagementPasswordFile)
+	}
+	if config.Server.String() != "10.8.0.0/24" || config.MaxClients != 100 || config.IfconfigPoolPersist != "../../example/ipp.txt" {
+		t.Errorf("pool is not parsed correctly: %+v", config)
+	}
+	if config.CA != "../../example/ca.crt" || config.Cert != "../../example/server.crt" || config.CRLVerify != "" {
+		t.Errorf("certificates are not parsed correctly: %+v", config)
+	}
+	if config.LogFile != "../../example/openvpn.log" {
+		t.Errorf("log file is not parsed correctly: %s", config.LogFile)
+	}
+	if _, ok := config.Inline["tls-crypt"]; !ok {
+		t.Errorf("inline files are not parsed correctly")
+	}
+}
+
+func TestParseCertificates(t *testing.T) {
+	config, err := parseConfig(strings.NewReader("crl-verify crl.pem\n<ca>\nPEM\n</ca>\n"), "/conf")
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if config.CRLVerify != "/conf/crl.pem" || config.Inline["ca"] != "PEM\n" {
+		t.Errorf("certificates are not parsed correctly: %+v", config)
+	}
+	config, _ = parseConfig(strings.NewReader("crl-verify /etc/openvpn/crl dir"), "/conf")
+	if config.CRLVerify != "" {
+		t.Errorf("CRL directories should be ignored")
+	}
+}
+
+var configTestCases = []struct {
+	scenarioName      string
+	config            string
+	statusFile        string
+	statusVersion     int
+	managementAddress string
+	passwordFile      string
+}{
+	{"empty", "", "", 1, "", ""},
+	{"absolute status", "status /run/openvpn/server.status", "/run/openvpn/server.status", 1, "", ""},
+	{"cd", "cd /etc/openvpn\nstatus server.status", "/etc/openvpn/server.status", 1, "", ""},
+	{"double dash", "--status server.status\n--status-version 3", "/conf/server.status", 3, "", ""},
+	{"quoted", `status "/run/open vpn/server.status"`, "/run/open vpn/server.status", 1, "", ""},
+	{"comment", "# status a.status\n; status b.status\nstatus c.status # inline", "/conf/c.status", 1, "", ""},
+	{"tcp management", "management 127.0.0.1 7505", "", 1, "127.0.0.1:7505", ""},
+	{"ipv6 management", "management ::1 7505 pw", "", 1, "[::1]:7505", "/conf/pw"},
+	{"stdin password", "management 127.0.0.1 7505 stdin", "", 1, "127.0.0.1:7505", ""},
+	{"unix management", "management mgmt.sock unix", "", 1, "unix:/conf/mgmt.sock", ""},
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, tt := range configTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			config, err := parseConfig(strings.NewReader(tt.config), "/conf")
+			if err != nil {
+				t.Fatalf("should have worked: %v", err)
+			}
+			if config.StatusFile != tt.statusFile || config.StatusVersion != tt.statusVersion {
+				t.Errorf("status is not parsed correctly: %+v", config)
+			}
+			if config.ManagementAddress != tt.managementAddress || config.ManagementPasswordFile != tt.passwordFile {
+				t.Errorf("management is not parsed correctly: %+v", config)
+			}
+		})
+	}
+}
+
+var invalidConfigTestCases = []struct {
+	scenarioName string
+	config       string
+}{
+	{"status without file", "status"},
+	{"bad status version", "status-version 4"},
+	{"management without port", "management 127.0.0.1"},
+	{"bad topology", "topology star"},
+	{"bad server", "server 10.8.0.0"},
+	{"bad server-ipv6", "server-ipv6 fd00::"},
+	{"bad ifconfig-pool", "ifconfig-pool 10.8.0.1 fd00::1"},
+	{"bad max-clients", "max-clients 0"},
+}
+
+func TestParseInvalidConfig(t *testing.T) {
+	for _, tt := range invalidConfigTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			if _, err := parseConfig(strings.NewReader(tt.config), "/conf"); err == nil {
+				t.Errorf("should have failed")
+			}
+		})
+	}
+}
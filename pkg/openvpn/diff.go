@@ -0,0 +1,41 @@
+package openvpn
+
+// SessionKey identifies the session of a client across status snapshots
+func (c Client) SessionKey() string {
+	return c.CommonName + "|" + c.RealAddress + "|" + c.RealPort
+}
+
+// DiffSessions compares two status snapshots and returns the sessions which appeared in
+// current and the sessions of previous which disappeared. A session which reconnected with a
+// new connected since is reported as both. Clients which are not authenticated yet (UNDEF)
+// are ignored.
+func DiffSessions(previous []Client, current []Client) ([]Client, []Client) {
+	previousSessions := sessionsByKey(previous)
+	currentSessions := sessionsByKey(current)
+	var connected, disconnected []Client
+	for _, client := range current {
+		if client.CommonName == "UNDEF" {
+			continue
+		}
+		if other, ok := previousSessions[client.SessionKey()]; !ok || !other.ConnectedSince.Equal(client.ConnectedSince) {
+			connected = append(connected, client)
+		}
+	}
+	for _, client := range previous {
+		if client.CommonName == "UNDEF" {
+			continue
+		}
+		if other, ok := currentSessions[client.SessionKey()]; !ok || !other.ConnectedSince.Equal(client.ConnectedSince) {
+			disconnected = append(disconnected, client)
+		}
+	}
+	return connected, disconnected
+}
+
+func sessionsByKey(clients []Client) map[string]Client {
+	sessions := make(map[string]Client, len(clients))
+	for _, client := range clients {
+		sessions[client.SessionKey()] = client
+	}
+	return sessions
+}
@@ -0,0 +1,25 @@
+package openvpn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffSessions(t *testing.T) {
+	foo := Client{CommonName: "foo", RealAddress: "1.2.3.4", RealPort: "1194", ConnectedSince: time.Unix(100, 0)}
+	fooReconnected := foo
+	fooReconnected.ConnectedSince = time.Unix(200, 0)
+	bar := Client{CommonName: "bar", RealAddress: "1.2.3.5", RealPort: "1194", ConnectedSince: time.Unix(100, 0)}
+	undef := Client{CommonName: "UNDEF", RealAddress: "1.2.3.6", RealPort: "1194"}
+
+	connected, disconnected := DiffSessions([]Client{foo, bar}, []Client{fooReconnected, undef})
+	if len(connected) != 1 || connected[0].ConnectedSince != fooReconnected.ConnectedSince {
+		t.Errorf("unexpected connected sessions: %+v", connected)
+	}
+	if len(disconnected) != 2 || disconnected[0].CommonName != "foo" || disconnected[1].CommonName != "bar" {
+		t.Errorf("unexpected disconnected sessions: %+v", disconnected)
+	}
+	if connected, disconnected := DiffSessions([]Client{foo}, []Client{foo}); len(connected) != 0 || len(disconnected) != 0 {
+		t.Errorf("unchanged sessions should not be reported")
+	}
+}
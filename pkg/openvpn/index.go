@@ -0,0 +1,118 @@
+package openvpn
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Certificate statuses of the index.txt database
+const (
+	CertificateValid   = "valid"
+	CertificateRevoked = "revoked"
+	CertificateExpired = "expired"
+)
+
+// IndexEntry reflects a certificate of the OpenSSL CA database (index.txt) maintained by easy-rsa
+type IndexEntry struct {
+	// Status is valid, revoked or expired as recorded in the database
+	Status           string
+	ExpiresAt        time.Time
+	RevokedAt        time.Time
+	RevocationReason string
+	Serial           string
+	Subject          string
+	CommonName       string
+}
+
+type indexError struct {
+	s string
+}
+
+func (e *indexError) Error() string {
+	return e.s
+}
+
+const (
+	utcTimeFormat         = "060102150405Z"
+	generalizedTimeFormat = "20060102150405Z"
+)
+
+// ParseIndexFile parses the index.txt database at path
+func ParseIndexFile(path string) ([]IndexEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseIndex(file)
+}
+
+func parseIndex(reader io.Reader) ([]IndexEntry, error) {
+	var entries []IndexEntry
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 6 {
+			return nil, &indexError{"bad index line " + line}
+		}
+		entry := IndexEntry{
+			Serial:     fields[3],
+			Subject:    fields[5],
+			CommonName: commonNameFromSubject(fields[5]),
+		}
+		switch fields[0] {
+		case "V":
+			entry.Status = CertificateValid
+		case "R":
+			entry.Status = CertificateRevoked
+		case "E":
+			entry.Status = CertificateExpired
+		default:
+			return nil, &indexError{"bad certificate status " + fields[0]}
+		}
+		expiresAt, err := parseIndexTime(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		entry.ExpiresAt = expiresAt
+		if entry.Status == CertificateRevoked {
+			revocation := strings.SplitN(fields[2], ",", 2)
+			revokedAt, err := parseIndexTime(revocation[0])
+			if err != nil {
+				return nil, err
+			}
+			entry.RevokedAt = revokedAt
+			if len(revocation) > 1 {
+				entry.RevocationReason = revocation[1]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// parseIndexTime parses the ASN.1 UTCTime or GeneralizedTime of the database
+func parseIndexTime(value string) (time.Time, error) {
+	if len(value) == len(generalizedTimeFormat) {
+		return time.Parse(generalizedTimeFormat, value)
+	}
+	return time.Parse(utcTimeFormat, value)
+}
+
+// commonNameFromSubject returns the common name of an OpenSSL one line subject
+// like /C=DE/O=Example/CN=user1/emailAddress=user1@example.com
+func commonNameFromSubject(subject string) string {
+	for _, part := range strings.Split(subject, "/") {
+		if strings.HasPrefix(part, "CN=") {
+			return strings.TrimPrefix(part, "CN=")
+		}
+	}
+	return ""
+}
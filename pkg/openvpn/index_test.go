@@ -0,0 +1,57 @@
+package openvpn
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIndexFile(t *testing.T) {
+	entries, err := ParseIndexFile("../../example/index.txt")
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(entries))
+	}
+	alice := entries[2]
+	if alice.Status != CertificateValid || alice.CommonName != "alice" || alice.Serial != "05" {
+		t.Errorf("entry is not parsed correctly: %+v", alice)
+	}
+	if !alice.ExpiresAt.Equal(time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("generalized time is not parsed correctly: %v", alice.ExpiresAt)
+	}
+	bob := entries[3]
+	if bob.Status != CertificateRevoked || bob.RevocationReason != "keyCompromise" {
+		t.Errorf("revocation is not parsed correctly: %+v", bob)
+	}
+	if !bob.RevokedAt.Equal(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("revocation time is not parsed correctly: %v", bob.RevokedAt)
+	}
+	if carol := entries[4]; carol.Status != CertificateExpired || carol.CommonName != "carol" {
+		t.Errorf("entry is not parsed correctly: %+v", carol)
+	}
+	if _, err := ParseIndexFile("../../example/missing.txt"); err == nil {
+		t.Errorf("should have failed on a missing file")
+	}
+}
+
+var indexErrorTestCases = []struct {
+	scenarioName string
+	index        string
+}{
+	{"missing fields", "V\t300101000000Z\t\t01\t/CN=server"},
+	{"unknown status", "X\t300101000000Z\t\t01\tunknown\t/CN=server"},
+	{"bad expiry", "V\t3001\t\t01\tunknown\t/CN=server"},
+	{"bad revocation", "R\t300101000000Z\t\t01\tunknown\t/CN=server"},
+}
+
+func TestParseIndexErrors(t *testing.T) {
+	for _, tt := range indexErrorTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			if _, err := parseIndex(strings.NewReader(tt.index)); err == nil {
+				t.Errorf("should have failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package openvpn
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authentication failure reasons of LogCounters.AuthFailures
+const (
+	AuthFailurePassword           = "password"
+	AuthFailureMissingCredentials = "missing_credentials"
+	AuthFailureClientConfigDir    = "client_config_dir"
+	AuthFailureCertificate        = "certificate"
+	AuthFailureCertificateExpired = "certificate_expired"
+	AuthFailureCertificateRevoked = "certificate_revoked"
+	AuthFailureVerifyScript       = "verify_script"
+	AuthFailureX509Name           = "x509_name"
+)
+
+// LogCounters contains the amount of failures and disconnects found in the openvpn log
+type LogCounters struct {
+	// AuthFailures counts failed authentications by reason
+	AuthFailures         map[string]float64
+	TLSHandshakeFailures float64
+	InactivityTimeouts   float64
+	// ClientDisconnects counts client instances exiting or restarting by signal reason, like
+	// ping-restart, remote-exit or connection-reset
+	ClientDisconnects map[string]float64
+}
+
+// LogTailer follows the log file of an openvpn instance and counts authentication and TLS
+// failures and client disconnects. Rotated files are read to the end before the new file is
+// followed, truncated files are followed from the start.
+type LogTailer struct {
+	path     string
+	interval time.Duration
+
+	file   *os.File
+	info   os.FileInfo
+	reader *bufio.Reader
+	offset int64
+	// partial keeps an incomplete last line until it is terminated
+	partial string
+
+	mu       sync.Mutex
+	lastErr  error
+	counters LogCounters
+}
+
+var (
+	clientInstanceSignal = regexp.MustCompile(`SIG\w+\[(?:soft|hard),([^\]]*)\] received, client-instance (?:exiting|restarting)`)
+	verifyError          = regexp.MustCompile(`VERIFY ERROR: .*error=([^:,]+)`)
+)
+
+// NewLogTailer returns a new LogTailer for the log file at path, which is checked for new lines
+// every interval
+func NewLogTailer(path string, interval time.Duration) *LogTailer {
+	return &LogTailer{
+		path:     path,
+		interval: interval,
+		counters: LogCounters{
+			AuthFailures:      make(map[string]float64),
+			ClientDisconnects: make(map[string]float64),
+		},
+	}
+}
+
+// Run follows the log file until ctx is done. Lines logged before Run are not counted.
+func (t *LogTailer) Run(ctx context.Context) {
+	if err := t.open(true); err != nil {
+		t.setErr(err)
+	}
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	defer t.close()
+	for {
+		t.setErr(t.poll())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the lines appended since the last poll and follows rotated or truncated files
+func (t *LogTailer) poll() error {
+	if t.file == nil {
+		if err := t.open(false); err != nil {
+			return err
+		}
+	}
+	if err := t.read(); err != nil {
+		return err
+	}
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// the file was rotated and the new file is not created yet
+		return nil
+	}
+	switch {
+	case !os.SameFile(info, t.info):
+		// lines written to the rotated file since the last read are still counted
+		if err := t.read(); err != nil {
+			return err
+		}
+		t.close()
+		if err := t.open(false); err != nil {
+			return err
+		}
+		return t.read()
+	case info.Size() < t.offset:
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.reader.Reset(t.file)
+		t.offset = 0
+		t.partial = ""
+		return t.read()
+	}
+	return nil
+}
+
+func (t *LogTailer) open(atEnd bool) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	t.offset = 0
+	if atEnd {
+		if t.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	t.file = file
+	t.info = info
+	t.reader = bufio.NewReader(file)
+	t.partial = ""
+	return nil
+}
+
+func (t *LogTailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+func (t *LogTailer) read() error {
+	for {
+		line, err := t.reader.ReadString('\n')
+		t.offset += int64(len(line))
+		if err == io.EOF {
+			t.partial += line
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.handle(t.partial + strings.TrimRight(line, "\r\n"))
+		t.partial = ""
+	}
+}
+
+// handle counts a single log line
+func (t *LogTailer) handle(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case strings.Contains(line, "Auth Username/Password verification failed"):
+		t.counters.AuthFailures[AuthFailurePassword]++
+	case strings.Contains(line, "Auth Username/Password was not provided by peer"):
+		t.counters.AuthFailures[AuthFailureMissingCredentials]++
+	case strings.Contains(line, "--client-config-dir authentication failed"):
+		t.counters.AuthFailures[AuthFailureClientConfigDir]++
+	case strings.Contains(line, "VERIFY SCRIPT ERROR"):
+		t.counters.AuthFailures[AuthFailureVerifyScript]++
+	case strings.Contains(line, "VERIFY X509NAME ERROR"):
+		t.counters.AuthFailures[AuthFailureX509Name]++
+	case strings.Contains(line, "VERIFY ERROR"):
+		reason := AuthFailureCertificate
+		if match := verifyError.FindStringSubmatch(line); match != nil {
+			switch match[1] {
+			case "certificate has expired":
+				reason = AuthFailureCertificateExpired
+			case "certificate revoked":
+				reason = AuthFailureCertificateRevoked
+			}
+		}
+		t.counters.AuthFailures[reason]++
+	case strings.Contains(line, "TLS Error: TLS handshake failed"):
+		t.counters.TLSHandshakeFailures++
+	case strings.Contains(line, "Inactivity timeout"):
+		t.counters.InactivityTimeouts++
+	default:
+		if match := clientInstanceSignal.FindStringSubmatch(line); match != nil {
+			reason := match[1]
+			if reason == "" {
+				reason = "unknown"
+			}
+			t.counters.ClientDisconnects[reason]++
+		}
+	}
+}
+
+func (t *LogTailer) setErr(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastErr = err
+}
+
+// Err returns the error of the last attempt to read the log file
+func (t *LogTailer) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastErr
+}
+
+// Counters returns a snapshot of the counters
+func (t *LogTailer) Counters() LogCounters {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counters := LogCounters{
+		AuthFailures:         make(map[string]float64, len(t.counters.AuthFailures)),
+		TLSHandshakeFailures: t.counters.TLSHandshakeFailures,
+		InactivityTimeouts:   t.counters.InactivityTimeouts,
+		ClientDisconnects:    make(map[string]float64, len(t.counters.ClientDisconnects)),
+	}
+	for reason, count := range t.counters.AuthFailures {
+		counters.AuthFailures[reason] = count
+	}
+	for reason, count := range t.counters.ClientDisconnects {
+		counters.ClientDisconnects[reason] = count
+	}
+	return counters
+}
@@ -0,0 +1,174 @@
+package openvpn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var logLineTestCases = []struct {
+	scenarioName string
+	line         string
+	expected     LogCounters
+}{
+	{
+		"password",
+		"2020-03-01 12:00:00 1.2.3.4:51234 TLS Auth Error: Auth Username/Password verification failed for peer",
+		LogCounters{AuthFailures: map[string]float64{AuthFailurePassword: 1}},
+	},
+	{
+		"missing credentials",
+		"1.2.3.4:51234 TLS Error: Auth Username/Password was not provided by peer",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureMissingCredentials: 1}},
+	},
+	{
+		"client config dir",
+		"1.2.3.4:51234 TLS Auth Error: --client-config-dir authentication failed for common name 'test' file='/etc/openvpn/ccd/test'",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureClientConfigDir: 1}},
+	},
+	{
+		"expired certificate",
+		"1.2.3.4:51234 VERIFY ERROR: depth=0, error=certificate has expired: CN=test, serial=2",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureCertificateExpired: 1}},
+	},
+	{
+		"revoked certificate",
+		"1.2.3.4:51234 VERIFY ERROR: depth=0, error=certificate revoked: CN=test, serial=3",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureCertificateRevoked: 1}},
+	},
+	{
+		"untrusted certificate",
+		"1.2.3.4:51234 VERIFY ERROR: depth=0, error=unable to get local issuer certificate: CN=test",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureCertificate: 1}},
+	},
+	{
+		"verify script",
+		"1.2.3.4:51234 VERIFY SCRIPT ERROR: depth=0, CN=test",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureVerifyScript: 1}},
+	},
+	{
+		"x509 name",
+		"1.2.3.4:51234 VERIFY X509NAME ERROR: CN=test, must be server",
+		LogCounters{AuthFailures: map[string]float64{AuthFailureX509Name: 1}},
+	},
+	{
+		"tls handshake",
+		"1.2.3.4:51234 TLS Error: TLS handshake failed",
+		LogCounters{TLSHandshakeFailures: 1},
+	},
+	{
+		"tls key negotiation is not counted twice",
+		"1.2.3.4:51234 TLS Error: TLS key negotiation failed to occur within 60 seconds (check your network connectivity)",
+		LogCounters{},
+	},
+	{
+		"inactivity timeout",
+		"test/1.2.3.4:51234 [test] Inactivity timeout (--ping-restart), restarting",
+		LogCounters{InactivityTimeouts: 1},
+	},
+	{
+		"ping restart",
+		"test/1.2.3.4:51234 SIGUSR1[soft,ping-restart] received, client-instance restarting",
+		LogCounters{ClientDisconnects: map[string]float64{"ping-restart": 1}},
+	},
+	{
+		"remote exit",
+		"test/1.2.3.4:51234 SIGTERM[soft,remote-exit] received, client-instance exiting",
+		LogCounters{ClientDisconnects: map[string]float64{"remote-exit": 1}},
+	},
+	{
+		"server signal",
+		"SIGTERM[hard,] received, process exiting",
+		LogCounters{},
+	},
+}
+
+func TestLogTailerHandle(t *testing.T) {
+	for _, tt := range logLineTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			tailer := NewLogTailer("", time.Second)
+			tailer.handle(tt.line)
+			assertLogCounters(t, tailer.Counters(), tt.expected)
+		})
+	}
+}
+
+func TestLogTailerFollowsRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "openvpn.log")
+	appendLog(t, path, "TLS Error: TLS handshake failed\n")
+
+	tailer := NewLogTailer(path, time.Second)
+	if err := tailer.open(true); err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	defer tailer.close()
+	appendLog(t, path, "TLS Error: TLS handshake failed\nInactivity")
+	if err := tailer.poll(); err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	// the history before the tailer was started is not counted, incomplete lines are kept
+	assertLogCounters(t, tailer.Counters(), LogCounters{TLSHandshakeFailures: 1})
+
+	appendLog(t, path, " timeout (--ping-restart), restarting\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("failed to rotate log: %v", err)
+	}
+	appendLog(t, path+".1", "TLS Error: TLS handshake failed\n")
+	appendLog(t, path, "TLS Error: TLS handshake failed\n")
+	if err := tailer.poll(); err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	assertLogCounters(t, tailer.Counters(), LogCounters{TLSHandshakeFailures: 3, InactivityTimeouts: 1})
+
+	// copytruncate
+	if err := ioutil.WriteFile(path, []byte("Inactivity timeout\n"), 0600); err != nil {
+		t.Fatalf("failed to truncate log: %v", err)
+	}
+	if err := tailer.poll(); err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	assertLogCounters(t, tailer.Counters(), LogCounters{TLSHandshakeFailures: 3, InactivityTimeouts: 2})
+}
+
+func appendLog(t *testing.T, path string, data string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+}
+
+func assertLogCounters(t *testing.T, counters LogCounters, expected LogCounters) {
+	if counters.TLSHandshakeFailures != expected.TLSHandshakeFailures {
+		t.Errorf("expected %v tls handshake failures, got %v", expected.TLSHandshakeFailures, counters.TLSHandshakeFailures)
+	}
+	if counters.InactivityTimeouts != expected.InactivityTimeouts {
+		t.Errorf("expected %v inactivity timeouts, got %v", expected.InactivityTimeouts, counters.InactivityTimeouts)
+	}
+	if len(counters.AuthFailures) != len(expected.AuthFailures) {
+		t.Errorf("expected auth failures %v, got %v", expected.AuthFailures, counters.AuthFailures)
+	}
+	for reason, count := range expected.AuthFailures {
+		if counters.AuthFailures[reason] != count {
+			t.Errorf("expected %v auth failures with reason %s, got %v", count, reason, counters.AuthFailures[reason])
+		}
+	}
+	if len(counters.ClientDisconnects) != len(expected.ClientDisconnects) {
+		t.Errorf("expected client disconnects %v, got %v", expected.ClientDisconnects, counters.ClientDisconnects)
+	}
+	for reason, count := range expected.ClientDisconnects {
+		if counters.ClientDisconnects[reason] != count {
+			t.Errorf("expected %v client disconnects with reason %s, got %v", count, reason, counters.ClientDisconnects[reason])
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package openvpn
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadStats reflects the load statistics reported by the management interface
+type LoadStats struct {
+	Clients  int
+	BytesIn  float64
+	BytesOut float64
+}
+
+// Management is a client for the openvpn management interface
+type Management struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	// Notify receives real-time notifications (lines starting with '>') which arrive
+	// while waiting for a command response or listening. Notifications are dropped if it is nil.
+	Notify func(notification string)
+}
+
+type managementError struct {
+	s string
+}
+
+func (e *managementError) Error() string {
+	return e.s
+}
+
+const (
+	passwordPrompt = "ENTER PASSWORD:"
+	unixPrefix     = "unix:"
+)
+
+// DialManagement connects to the openvpn management interface listening on address and
+// authenticates with password if the interface asks for it. The address is either
+// host:port for a tcp or unix:/path/to/socket for a unix socket management interface.
+func DialManagement(address string, password string, timeout time.Duration) (*Management, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, unixPrefix) {
+		network = "unix"
+		address = strings.TrimPrefix(address, unixPrefix)
+	}
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	m := &Management{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+	if err := m.authenticate(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Management) authenticate(password string) error {
+	m.setDeadline()
+	prompt, err := m.reader.Peek(len(passwordPrompt))
+	if err != nil {
+		return err
+	}
+	if string(prompt) != passwordPrompt {
+		return nil
+	}
+	if password == "" {
+		return &managementError{"management interface requires a password"}
+	}
+	if _, err := m.reader.Discard(len(passwordPrompt)); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(m.conn, "%s\n", password); err != nil {
+		return err
+	}
+	line, err := m.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "SUCCESS:") {
+		return &managementError{"management interface authentication failed: " + line}
+	}
+	return nil
+}
+
+// Close ends the management session and closes the connection
+func (m *Management) Close() error {
+	m.setDeadline()
+	_, _ = fmt.Fprint(m.conn, "quit\n")
+	return m.conn.Close()
+}
+
+// Status issues `status 3` and parses the response
+func (m *Management) Status() (*Status, error) {
+	lines, err := m.commandMultiLine("status 3")
+	if err != nil {
+		return nil, err
+	}
+	return parse(bufio.NewReader(strings.NewReader(strings.Join(lines, "\n") + "\nEND\n")))
+}
+
+// Version issues `version` and returns the openvpn and management interface version
+func (m *Management) Version() (ServerInfo, string, error) {
+	lines, err := m.commandMultiLine("version")
+	if err != nil {
+		return ServerInfo{}, "", err
+	}
+	var serverInfo ServerInfo
+	var managementVersion string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "OpenVPN Version: ") {
+			serverInfo = parseServerInfo(strings.TrimPrefix(line, "OpenVPN Version: "))
+		} else if strings.HasPrefix(line, "Management Version: ") {
+			managementVersion = strings.TrimPrefix(line, "Management Version: ")
+		}
+	}
+	return serverInfo, managementVersion, nil
+}
+
+// LoadStats issues `load-stats` and parses the response
+func (m *Management) LoadStats() (*LoadStats, error) {
+	line, err := m.command("load-stats")
+	if err != nil {
+		return nil, err
+	}
+	var stats LoadStats
+	for _, pair := range strings.Split(line, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "nclients":
+			stats.Clients, _ = strconv.Atoi(kv[1])
+		case "bytesin":
+			stats.BytesIn, _ = strconv.ParseFloat(kv[1], 64)
+		case "bytesout":
+			stats.BytesOut, _ = strconv.ParseFloat(kv[1], 64)
+		}
+	}
+	return &stats, nil
+}
+
+// Bytecount enables real-time BYTECOUNT notifications every interval
+func (m *Management) Bytecount(interval time.Duration) error {
+	_, err := m.command(fmt.Sprintf("bytecount %d", int(interval.Seconds())))
+	return err
+}
+
+// Listen forwards all real-time notifications to Notify until the connection fails or is closed
+func (m *Management) Listen() error {
+	_ = m.conn.SetDeadline(time.Time{})
+	for {
+		line, err := m.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, ">") && m.Notify != nil {
+			m.Notify(line)
+		}
+	}
+}
+
+// command sends a command with a single line SUCCESS response and returns
+// the response without its SUCCESS: prefix
+func (m *Management) command(cmd string) (string, error) {
+	if err := m.send(cmd); err != nil {
+		return "", err
+	}
+	line, err := m.readResponseLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "SUCCESS:") {
+		return "", &managementError{fmt.Sprintf("unexpected response to %q: %s", cmd, line)}
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "SUCCESS:")), nil
+}
+
+// commandMultiLine sends a command with a response terminated by END and returns
+// all lines of the response without the END marker
+func (m *Management) commandMultiLine(cmd string) ([]string, error) {
+	if err := m.send(cmd); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		line, err := m.readResponseLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return lines, nil
+		}
+		if len(lines) == 0 && strings.HasPrefix(line, "ERROR:") {
+			return nil, &managementError{fmt.Sprintf("error response to %q: %s", cmd, line)}
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (m *Management) send(cmd string) error {
+	m.setDeadline()
+	_, err := fmt.Fprintf(m.conn, "%s\n", cmd)
+	return err
+}
+
+// readResponseLine returns the next line which is not a real-time notification
+func (m *Management) readResponseLine() (string, error) {
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(line, ">") {
+			return line, nil
+		}
+		if m.Notify != nil {
+			m.Notify(line)
+		}
+	}
+}
+
+func (m *Management) readLine() (string, error) {
+	m.setDeadline()
+	line, err := m.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (m *Management) setDeadline() {
+	if m.timeout > 0 {
+		_ = m.conn.SetDeadline(time.Now().Add(m.timeout))
+	}
+}
+
+// ParseManagement queries the openvpn management interface and returns the respective stats
+func ParseManagement(address string, password string, timeout time.Duration) (*Status, error) {
+	m, err := DialManagement(address, password, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	status, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	serverInfo, managementVersion, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if serverInfo.Version != "" {
+		status.ServerInfo = serverInfo
+	}
+	status.ServerInfo.ManagementVersion = managementVersion
+	status.LoadStats, err = m.LoadStats()
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
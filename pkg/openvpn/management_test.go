@@ -0,0 +1,111 @@
+package openvpn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn/openvpntest"
+)
+
+func newFakeManagement(t *testing.T, network string, address string, password string, responses map[string]string) *openvpntest.Management {
+	f := openvpntest.NewManagement(network, address, password, responses)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func defaultManagementResponses() map[string]string {
+	return openvpntest.Responses(connectedClientsV3)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "openvpn_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestParseManagement(t *testing.T) {
+	f := newFakeManagement(t, "tcp", "127.0.0.1:0", "", defaultManagementResponses())
+	status, err := ParseManagement(f.Address(), "", time.Second)
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if len(status.ClientList) != 2 {
+		t.Errorf("Clients are not parsed correctly")
+	}
+	if !status.UpdatedAt.Equal(time.Unix(1588254944, 0)) {
+		t.Errorf("failed parsing updated at")
+	}
+	if status.ServerInfo.Version != "2.4.4" || status.ServerInfo.ManagementVersion != "1" {
+		t.Errorf("version is not parsed correctly: %+v", status.ServerInfo)
+	}
+	if status.LoadStats == nil {
+		t.Fatalf("load stats are not parsed")
+	}
+	if *status.LoadStats != (LoadStats{Clients: 2, BytesIn: 7731, BytesOut: 7612}) {
+		t.Errorf("load stats are not parsed correctly: %+v", *status.LoadStats)
+	}
+}
+
+func TestParseManagementWithPassword(t *testing.T) {
+	f := newFakeManagement(t, "tcp", "127.0.0.1:0", "secret", defaultManagementResponses())
+	if _, err := ParseManagement(f.Address(), "secret", time.Second); err != nil {
+		t.Errorf("should have worked: %v", err)
+	}
+	if _, err := ParseManagement(f.Address(), "wrong", time.Second); err == nil {
+		t.Errorf("should have failed with a wrong password")
+	}
+	if _, err := ParseManagement(f.Address(), "", time.Second); err == nil {
+		t.Errorf("should have failed without a password")
+	}
+}
+
+func TestParseManagementViaUnixSocket(t *testing.T) {
+	f := newFakeManagement(t, "unix", filepath.Join(tempDir(t), "management.sock"), "", defaultManagementResponses())
+	status, err := ParseManagement(f.Address(), "", time.Second)
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if len(status.ClientList) != 2 {
+		t.Errorf("Clients are not parsed correctly")
+	}
+}
+
+func TestManagementForwardsNotifications(t *testing.T) {
+	f := newFakeManagement(t, "tcp", "127.0.0.1:0", "", defaultManagementResponses())
+	m, err := DialManagement(f.Address(), "", time.Second)
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	defer m.Close()
+	var notifications []string
+	m.Notify = func(notification string) {
+		notifications = append(notifications, notification)
+	}
+	if _, err := m.LoadStats(); err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if len(notifications) != 2 || notifications[1] != ">BYTECOUNT_CLI:0,100,200" {
+		t.Errorf("notifications are not forwarded: %v", notifications)
+	}
+}
+
+func TestManagementErrorResponse(t *testing.T) {
+	responses := defaultManagementResponses()
+	delete(responses, "version")
+	f := newFakeManagement(t, "tcp", "127.0.0.1:0", "", responses)
+	if _, err := ParseManagement(f.Address(), "", time.Second); err == nil {
+		t.Errorf("should have failed on an error response")
+	}
+}
+
+func TestErrorOnUnreachableManagement(t *testing.T) {
+	if _, err := ParseManagement(filepath.Join(unixPrefix, tempDir(t), "missing.sock"), "", time.Second); err == nil {
+		t.Errorf("should have failed on unreachable management interface")
+	}
+}
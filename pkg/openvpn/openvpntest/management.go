@@ -0,0 +1,140 @@
+// Package openvpntest provides a fake OpenVPN management interface for tests.
+package openvpntest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Version is the response of the version command
+const Version = `OpenVPN Version: OpenVPN 2.4.4 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [PKCS11] [MH/PKTINFO] [AEAD] built on May 14 2019
+Management Version: 1
+END
+`
+
+// unixPrefix is the prefix of unix socket addresses of the management interface
+const unixPrefix = "unix:"
+
+// passwordPrompt is sent before the banner if a password is required
+const passwordPrompt = "ENTER PASSWORD:"
+
+// Management is a minimal in-process openvpn management interface answering commands with
+// canned responses
+type Management struct {
+	listener net.Listener
+	password string
+	// responses maps commands to their raw response
+	responses map[string]string
+	// notifications are pushed to connected clients after the banner
+	notifications chan string
+}
+
+// Responses returns the responses of a server with two clients, status is the response of
+// the status 3 command and should list them
+func Responses(status string) map[string]string {
+	return map[string]string{
+		"status 3": status,
+		"version":  Version,
+		// real-time notifications may arrive before a command response
+		"load-stats":  ">BYTECOUNT_CLI:0,100,200\nSUCCESS: nclients=2,bytesin=7731,bytesout=7612\n",
+		"bytecount 5": "SUCCESS: bytecount interval changed\n",
+	}
+}
+
+// NewManagement starts a management interface listening on address, it requires password
+// if it is not empty. It panics if listening fails.
+func NewManagement(network string, address string, password string, responses map[string]string) *Management {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		panic(fmt.Sprintf("openvpntest: failed to listen on %s: %v", address, err))
+	}
+	m := &Management{
+		listener:      listener,
+		password:      password,
+		responses:     responses,
+		notifications: make(chan string),
+	}
+	go m.serve()
+	return m
+}
+
+// Address returns the address of the management interface
+func (m *Management) Address() string {
+	if m.listener.Addr().Network() == "unix" {
+		return unixPrefix + m.listener.Addr().String()
+	}
+	return m.listener.Addr().String()
+}
+
+// Notify pushes a real-time notification to a connected client
+func (m *Management) Notify(notification string) {
+	m.notifications <- notification
+}
+
+// Close stops listening for connections
+func (m *Management) Close() {
+	m.listener.Close()
+}
+
+func (m *Management) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.handle(conn)
+	}
+}
+
+func (m *Management) handle(conn net.Conn) {
+	defer conn.Close()
+	var mu sync.Mutex
+	done := make(chan struct{})
+	defer close(done)
+	reader := bufio.NewReader(conn)
+	if m.password != "" {
+		fmt.Fprint(conn, passwordPrompt)
+		password, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(password) != m.password {
+			fmt.Fprint(conn, "ERROR: bad password\n")
+			return
+		}
+		fmt.Fprint(conn, "SUCCESS: password is correct\n")
+	}
+	fmt.Fprint(conn, ">INFO:OpenVPN Management Interface Version 1 -- type 'help' for more info\n")
+	go func() {
+		for {
+			select {
+			case notification := <-m.notifications:
+				mu.Lock()
+				fmt.Fprint(conn, notification)
+				mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		if cmd == "quit" {
+			return
+		}
+		response, ok := m.responses[cmd]
+		if !ok {
+			response = "ERROR: unknown command, enter 'help' for more options\n"
+		}
+		mu.Lock()
+		fmt.Fprint(conn, response)
+		mu.Unlock()
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"io"
-	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -18,11 +17,43 @@ type GlobalStats struct {
 
 // Client struct store information from openvpn client statistics
 type Client struct {
+	CommonName         string
+	RealAddress        string
+	RealPort           string
+	Protocol           string
+	VirtualAddress     string
+	VirtualIPv6Address string
+	Username           string
+	ClientID           string
+	PeerID             string
+	BytesReceived      float64
+	BytesSent          float64
+	ConnectedSince     time.Time
+	// Attributes contains all status columns which are not mapped to a field
+	Attributes map[string]string
+	// PeerInfo contains the IV_* variables the client sent, only available via management notifications
+	PeerInfo map[string]string
+}
+
+// Statistics reflects the traffic statistics of a point-to-point or client mode instance
+type Statistics struct {
+	TunTapReadBytes     float64
+	TunTapWriteBytes    float64
+	TCPUDPReadBytes     float64
+	TCPUDPWriteBytes    float64
+	AuthReadBytes       float64
+	PreCompressBytes    float64
+	PostCompressBytes   float64
+	PreDecompressBytes  float64
+	PostDecompressBytes float64
+}
+
+// Route reflects a single entry of the openvpn routing table
+type Route struct {
+	VirtualAddress string
 	CommonName     string
 	RealAddress    string
-	BytesReceived  float64
-	BytesSent      float64
-	ConnectedSince time.Time
+	LastRef        time.Time
 }
 
 // ServerInfo reflects information that was collected about the server
@@ -30,14 +61,24 @@ type ServerInfo struct {
 	Version        string
 	Arch           string
 	AdditionalInfo string
+	// ManagementVersion is only available when queried via the management interface
+	ManagementVersion string
 }
 
 // Status reflects all information in a status log
 type Status struct {
 	ClientList  []Client
+	Routes      []Route
 	GlobalStats GlobalStats
 	ServerInfo  ServerInfo
 	UpdatedAt   time.Time
+	// Statistics is only available for point-to-point or client mode instances
+	Statistics *Statistics
+	// LoadStats is only available when queried via the management interface
+	LoadStats *LoadStats
+	// Version is the status-version of the format, 0 for the statistics of point-to-point or
+	// client mode instances
+	Version int
 }
 
 type parseError struct {
@@ -48,10 +89,88 @@ func (e *parseError) Error() string {
 	return e.s
 }
 
+var (
+	// ErrUnknownFormat is returned for status files which are in none of the known formats
+	ErrUnknownFormat error = &parseError{"unknown status file format"}
+	// ErrTruncated is returned for status files which are incomplete, like a file read while
+	// openvpn rewrites it which is empty or lacks the trailing END marker
+	ErrTruncated error = &parseError{"truncated status file"}
+)
+
 const (
 	timefmt = "Mon Jan 2 15:04:05 2006"
 )
 
+// column names used in the HEADER rows of status version 2 and 3
+const (
+	columnCommonName          = "Common Name"
+	columnRealAddress         = "Real Address"
+	columnVirtualAddress      = "Virtual Address"
+	columnVirtualIPv6Address  = "Virtual IPv6 Address"
+	columnUsername            = "Username"
+	columnClientID            = "Client ID"
+	columnPeerID              = "Peer ID"
+	columnBytesReceived       = "Bytes Received"
+	columnBytesSent           = "Bytes Sent"
+	columnConnectedSince      = "Connected Since"
+	columnConnectedSinceTimeT = "Connected Since (time_t)"
+	columnLastRef             = "Last Ref"
+	columnLastRefTimeT        = "Last Ref (time_t)"
+)
+
+// defaultClientListHeader is used when a status file does not provide a HEADER row for CLIENT_LIST
+var defaultClientListHeader = []string{
+	columnCommonName,
+	columnRealAddress,
+	columnVirtualAddress,
+	columnVirtualIPv6Address,
+	columnBytesReceived,
+	columnBytesSent,
+	columnConnectedSince,
+	columnConnectedSinceTimeT,
+	columnUsername,
+	columnClientID,
+	columnPeerID,
+}
+
+// defaultRoutingTableHeader is used when a status file does not provide a HEADER row for ROUTING_TABLE
+var defaultRoutingTableHeader = []string{
+	columnVirtualAddress,
+	columnCommonName,
+	columnRealAddress,
+	columnLastRef,
+	columnLastRefTimeT,
+}
+
+// row maps the column names of a HEADER row to the values of a status line
+type row map[string]string
+
+func newRow(header []string, values []string) row {
+	r := make(row, len(header))
+	for i, column := range header {
+		if i < len(values) {
+			r[column] = values[i]
+		}
+	}
+	return r
+}
+
+// take returns the value of a column and removes it from the row
+func (r row) take(column string) string {
+	value := r[column]
+	delete(r, column)
+	return value
+}
+
+// takeTime returns the time of a column preferring its time_t variant
+func (r row) takeTime(column string, columnTimeT string) time.Time {
+	value, timeT := r.take(column), r.take(columnTimeT)
+	if i, err := strconv.ParseInt(timeT, 10, 64); err == nil {
+		return time.Unix(i, 0)
+	}
+	return parseTime(value)
+}
+
 // ParseFile parses a openvpn status log and returns respective stats
 func ParseFile(statusfile string) (*Status, error) {
 	conn, err := os.Open(statusfile)
@@ -72,22 +191,101 @@ func parseTime(t string) time.Time {
 	return t2
 }
 
-func parseIP(ip string) string {
-	return net.ParseIP(strings.Split(ip, ":")[0]).String()
+// parseServerInfo parses a version string like "OpenVPN 2.4.4 x86_64-pc-linux-gnu [SSL (OpenSSL)] ..."
+func parseServerInfo(title string) ServerInfo {
+	infoFields := strings.Split(title, " ")
+	if len(infoFields) < 3 {
+		return ServerInfo{}
+	}
+	return ServerInfo{
+		Version:        infoFields[1],
+		Arch:           infoFields[2],
+		AdditionalInfo: strings.Join(infoFields[3:], " "),
+	}
+}
+
+func parseClient(r row) Client {
+	bytesRec, _ := strconv.ParseFloat(r.take(columnBytesReceived), 64)
+	bytesSent, _ := strconv.ParseFloat(r.take(columnBytesSent), 64)
+	address := parseAddress(r.take(columnRealAddress))
+	return Client{
+		CommonName:         r.take(columnCommonName),
+		RealAddress:        address.Host,
+		RealPort:           address.Port,
+		Protocol:           address.Protocol,
+		VirtualAddress:     r.take(columnVirtualAddress),
+		VirtualIPv6Address: r.take(columnVirtualIPv6Address),
+		Username:           r.take(columnUsername),
+		ClientID:           r.take(columnClientID),
+		PeerID:             r.take(columnPeerID),
+		BytesReceived:      bytesRec,
+		BytesSent:          bytesSent,
+		ConnectedSince:     r.takeTime(columnConnectedSince, columnConnectedSinceTimeT),
+		Attributes:         r,
+	}
+}
+
+func parseRoute(r row) Route {
+	return Route{
+		VirtualAddress: r.take(columnVirtualAddress),
+		CommonName:     r.take(columnCommonName),
+		RealAddress:    parseAddress(r.take(columnRealAddress)).Host,
+		LastRef:        r.takeTime(columnLastRef, columnLastRefTimeT),
+	}
 }
 
 func parse(reader *bufio.Reader) (*Status, error) {
 	buf, _ := reader.Peek(19)
+	if len(buf) == 0 {
+		return nil, ErrTruncated
+	}
 	if bytes.HasPrefix(buf, []byte("OpenVPN CLIENT LIST")) {
-		return parseStatusV1(reader)
+		status, err := parseStatusV1(reader)
+		return withVersion(status, err, 1)
+	}
+	if bytes.HasPrefix(buf, []byte("OpenVPN STATISTICS")) {
+		return parseStatistics(reader)
 	}
 	if bytes.HasPrefix(buf, []byte("TITLE,OpenVPN")) {
-		return parseStatusV2AndV3(reader, ",")
+		status, err := parseStatusV2AndV3(reader, ",")
+		return withVersion(status, err, 2)
 	}
 	if bytes.HasPrefix(buf, []byte("TITLE\tOpenVPN")) {
-		return parseStatusV2AndV3(reader, "\t")
+		status, err := parseStatusV2AndV3(reader, "\t")
+		return withVersion(status, err, 3)
+	}
+	// a file ending within the first line of a known format was caught mid-write
+	for _, prefix := range []string{"OpenVPN CLIENT LIST", "OpenVPN STATISTICS", "TITLE,OpenVPN", "TITLE\tOpenVPN"} {
+		if bytes.HasPrefix([]byte(prefix), buf) {
+			return nil, ErrTruncated
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// withVersion sets the status-version of a successfully parsed status
+func withVersion(status *Status, err error, version int) (*Status, error) {
+	if err != nil {
+		return nil, err
+	}
+	status.Version = version
+	return status, nil
+}
+
+// isEnd reports whether the fields are the END marker openvpn writes as the last line
+func isEnd(fields []string) bool {
+	return len(fields) == 1 && strings.TrimSpace(fields[0]) == "END"
+}
+
+// checkComplete reports an error if the status could not be read to the END marker
+func checkComplete(scanner *bufio.Scanner, complete bool) error {
+	if err := scanner.Err(); err != nil {
+		return err
 	}
-	return nil, &parseError{"bad status file"}
+	if !complete {
+		return ErrTruncated
+	}
+	return nil
 }
 
 func parseStatusV1(reader io.Reader) (*Status, error) {
@@ -95,11 +293,17 @@ func parseStatusV1(reader io.Reader) (*Status, error) {
 	var lastUpdatedAt time.Time
 	var maxBcastMcastQueueLen int
 	var clients []Client
+	var routes []Route
+	complete := false
 	for scanner.Scan() {
 		fields := strings.Split(scanner.Text(), ",")
+		if isEnd(fields) {
+			complete = true
+			break
+		}
 		if fields[0] == "Updated" && len(fields) == 2 {
 			lastUpdatedAt = parseTime(fields[1])
-		} else if fields[0] == "Max bcast/mcast queue length" {
+		} else if fields[0] == "Max bcast/mcast queue length" && len(fields) == 2 {
 			i, err := strconv.Atoi(fields[1])
 			if err == nil {
 				maxBcastMcastQueueLen = i
@@ -108,66 +312,130 @@ func parseStatusV1(reader io.Reader) (*Status, error) {
 			if fields[0] != "Common Name" {
 				bytesRec, _ := strconv.ParseFloat(fields[2], 64)
 				bytesSent, _ := strconv.ParseFloat(fields[3], 64)
+				address := parseAddress(fields[1])
 				client := Client{
 					CommonName:     fields[0],
-					RealAddress:    parseIP(fields[1]),
+					RealAddress:    address.Host,
+					RealPort:       address.Port,
+					Protocol:       address.Protocol,
 					BytesReceived:  bytesRec,
 					BytesSent:      bytesSent,
 					ConnectedSince: parseTime(fields[4]),
 				}
 				clients = append(clients, client)
 			}
+		} else if len(fields) == 4 {
+			if fields[0] != "Virtual Address" {
+				route := Route{
+					VirtualAddress: fields[0],
+					CommonName:     fields[1],
+					RealAddress:    parseAddress(fields[2]).Host,
+					LastRef:        parseTime(fields[3]),
+				}
+				routes = append(routes, route)
+			}
 		}
 	}
+	if err := checkComplete(scanner, complete); err != nil {
+		return nil, err
+	}
 	return &Status{
 		GlobalStats: GlobalStats{maxBcastMcastQueueLen},
 		UpdatedAt:   lastUpdatedAt,
 		ClientList:  clients,
+		Routes:      routes,
 		ServerInfo:  ServerInfo{Version: "unknown", Arch: "unknown", AdditionalInfo: "unknown"},
 	}, nil
 }
 
+func parseStatistics(reader io.Reader) (*Status, error) {
+	scanner := bufio.NewScanner(reader)
+	var lastUpdatedAt time.Time
+	var stats Statistics
+	counters := map[string]*float64{
+		"TUN/TAP read bytes":    &stats.TunTapReadBytes,
+		"TUN/TAP write bytes":   &stats.TunTapWriteBytes,
+		"TCP/UDP read bytes":    &stats.TCPUDPReadBytes,
+		"TCP/UDP write bytes":   &stats.TCPUDPWriteBytes,
+		"Auth read bytes":       &stats.AuthReadBytes,
+		"pre-compress bytes":    &stats.PreCompressBytes,
+		"post-compress bytes":   &stats.PostCompressBytes,
+		"pre-decompress bytes":  &stats.PreDecompressBytes,
+		"post-decompress bytes": &stats.PostDecompressBytes,
+	}
+	complete := false
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if isEnd(fields) {
+			complete = true
+			break
+		}
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[0] == "Updated" {
+			lastUpdatedAt = parseTime(fields[1])
+		} else if counter, ok := counters[fields[0]]; ok {
+			value, err := strconv.ParseFloat(fields[1], 64)
+			if err == nil {
+				*counter = value
+			}
+		}
+	}
+	if err := checkComplete(scanner, complete); err != nil {
+		return nil, err
+	}
+	return &Status{
+		UpdatedAt:  lastUpdatedAt,
+		ServerInfo: ServerInfo{Version: "unknown", Arch: "unknown", AdditionalInfo: "unknown"},
+		Statistics: &stats,
+	}, nil
+}
+
 func parseStatusV2AndV3(reader io.Reader, separator string) (*Status, error) {
 	scanner := bufio.NewScanner(reader)
 	var maxBcastMcastQueueLen int
 	var lastUpdatedAt time.Time
 	var clients []Client
+	var routes []Route
 	var serverInfo ServerInfo
+	headers := map[string][]string{
+		"CLIENT_LIST":   defaultClientListHeader,
+		"ROUTING_TABLE": defaultRoutingTableHeader,
+	}
+	complete := false
 	for scanner.Scan() {
 		fields := strings.Split(scanner.Text(), separator)
+		if isEnd(fields) {
+			complete = true
+			break
+		}
 		if fields[0] == "TIME" && len(fields) == 3 {
 			updatedAtInt, _ := strconv.ParseInt(fields[2], 10, 64)
 			lastUpdatedAt = time.Unix(updatedAtInt, 0)
-		} else if fields[0] == "CLIENT_LIST" {
-			bytesRec, _ := strconv.ParseFloat(fields[5], 64)
-			bytesSent, _ := strconv.ParseFloat(fields[6], 64)
-			connectedSinceInt, _ := strconv.ParseInt(fields[8], 10, 64)
-			client := Client{
-				CommonName:     fields[1],
-				RealAddress:    parseIP(fields[2]),
-				BytesReceived:  bytesRec,
-				BytesSent:      bytesSent,
-				ConnectedSince: time.Unix(connectedSinceInt, 0),
-			}
-			clients = append(clients, client)
-		} else if fields[0] == "GLOBAL_STATS" {
+		} else if fields[0] == "HEADER" && len(fields) > 2 {
+			headers[fields[1]] = fields[2:]
+		} else if fields[0] == "CLIENT_LIST" && len(fields) > 1 {
+			clients = append(clients, parseClient(newRow(headers[fields[0]], fields[1:])))
+		} else if fields[0] == "ROUTING_TABLE" && len(fields) > 1 {
+			routes = append(routes, parseRoute(newRow(headers[fields[0]], fields[1:])))
+		} else if fields[0] == "GLOBAL_STATS" && len(fields) == 3 {
 			i, err := strconv.Atoi(fields[2])
 			if err == nil {
 				maxBcastMcastQueueLen = i
 			}
-		} else if fields[0] == "TITLE" {
-			infoFields := strings.Split(fields[1], " ")
-			serverInfo = ServerInfo{
-				Version:        infoFields[1],
-				Arch:           infoFields[2],
-				AdditionalInfo: strings.Join(infoFields[3:], " "),
-			}
+		} else if fields[0] == "TITLE" && len(fields) > 1 {
+			serverInfo = parseServerInfo(fields[1])
 		}
 	}
+	if err := checkComplete(scanner, complete); err != nil {
+		return nil, err
+	}
 	return &Status{
 		GlobalStats: GlobalStats{maxBcastMcastQueueLen},
 		UpdatedAt:   lastUpdatedAt,
 		ClientList:  clients,
+		Routes:      routes,
 		ServerInfo:  serverInfo,
 	}, nil
 }
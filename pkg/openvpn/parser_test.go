@@ -2,6 +2,7 @@ package openvpn
 
 import (
 	"bufio"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -16,11 +17,78 @@ func TestErrorOnNonExistentFile(t *testing.T) {
 
 func TestParsingEmptyFile(t *testing.T) {
 	_, e := parse(bufio.NewReader(strings.NewReader("")))
-	if e == nil || e.Error() != "bad status file" {
+	if !errors.Is(e, ErrTruncated) {
 		t.Errorf("Should have errorred on empty status file")
 	}
 }
 
+func TestParsingUnknownFormat(t *testing.T) {
+	_, e := parse(bufio.NewReader(strings.NewReader("<html>\n")))
+	if !errors.Is(e, ErrUnknownFormat) {
+		t.Errorf("Should have errorred on unknown status file format: %v", e)
+	}
+}
+
+var truncatedTestCases = []struct {
+	StatusVersionName  string
+	StatusFileContents string
+}{
+	{"v1", connectedClientsV1},
+	{"v2", connectedClientsV2},
+	{"v3", connectedClientsV3},
+	{"statistics", statistics},
+}
+
+var statusVersionTestCases = []struct {
+	StatusVersionName  string
+	StatusFileContents string
+	expected           int
+}{
+	{"v1", connectedClientsV1, 1},
+	{"v2", connectedClientsV2, 2},
+	{"v3", connectedClientsV3, 3},
+	// the statistics of point-to-point and client mode instances have no version
+	{"statistics", statistics, 0},
+}
+
+func TestStatusVersion(t *testing.T) {
+	for _, tt := range statusVersionTestCases {
+		t.Run(tt.StatusVersionName, func(t *testing.T) {
+			status, err := parse(bufio.NewReader(strings.NewReader(tt.StatusFileContents)))
+			if err != nil {
+				t.Fatalf("should have worked: %v", err)
+			}
+			if status.Version != tt.expected {
+				t.Errorf("expected status-version %d, got %d", tt.expected, status.Version)
+			}
+		})
+	}
+}
+
+func TestParsingTruncatedFile(t *testing.T) {
+	for _, tt := range truncatedTestCases {
+		t.Run(tt.StatusVersionName, func(t *testing.T) {
+			// cut the status file at every byte before the END marker, which includes every
+			// line boundary and every position within a line
+			end := strings.LastIndex(tt.StatusFileContents, "END")
+			for cut := 0; cut < end; cut++ {
+				contents := tt.StatusFileContents[:cut]
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("Parsing status file cut at %d panicked: %v", cut, r)
+						}
+					}()
+					_, e := parse(bufio.NewReader(strings.NewReader(contents)))
+					if !errors.Is(e, ErrTruncated) {
+						t.Errorf("Should have errorred on status file cut at %d: %v", cut, e)
+					}
+				}()
+			}
+		})
+	}
+}
+
 const noConnectedClientsV1 = `OpenVPN CLIENT LIST
 Updated,Thu Apr 23 20:14:31 2020
 Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
@@ -189,6 +257,47 @@ func TestParsingWrongValuesIsNotAnIssue(t *testing.T) {
 	}
 }
 
+var shortLinesTestCases = []struct {
+	StatusVersionName  string
+	StatusFileContents string
+	Line               string
+}{
+	{"v1 updated", noConnectedClientsV1, "Updated"},
+	{"v1 max bcast/mcast queue length", noConnectedClientsV1, "Max bcast/mcast queue length"},
+	{"v1 client", noConnectedClientsV1, "user1"},
+	{"statistics updated", statistics, "Updated"},
+	{"statistics counter", statistics, "TUN/TAP read bytes"},
+	{"v2 title", noConnectedClientsV2, "TITLE"},
+	{"v2 time", noConnectedClientsV2, "TIME"},
+	{"v2 header", noConnectedClientsV2, "HEADER"},
+	{"v2 client list", noConnectedClientsV2, "CLIENT_LIST"},
+	{"v2 routing table", noConnectedClientsV2, "ROUTING_TABLE"},
+	{"v2 global stats", noConnectedClientsV2, "GLOBAL_STATS"},
+	{"v3 title", noConnectedClientsV3, "TITLE"},
+	{"v3 time", noConnectedClientsV3, "TIME"},
+	{"v3 header", noConnectedClientsV3, "HEADER"},
+	{"v3 client list", noConnectedClientsV3, "CLIENT_LIST"},
+	{"v3 routing table", noConnectedClientsV3, "ROUTING_TABLE"},
+	{"v3 global stats", noConnectedClientsV3, "GLOBAL_STATS"},
+}
+
+func TestParsingShortLinesIsNotAnIssue(t *testing.T) {
+	for _, tt := range shortLinesTestCases {
+		t.Run(tt.StatusVersionName, func(t *testing.T) {
+			// the line with a single field is inserted after the first line
+			i := strings.Index(tt.StatusFileContents, "\n") + 1
+			contents := tt.StatusFileContents[:i] + tt.Line + "\n" + tt.StatusFileContents[i:]
+			status, e := parse(bufio.NewReader(strings.NewReader(contents)))
+			if e != nil {
+				t.Fatalf("should have worked: %v", e)
+			}
+			if len(status.ClientList) != 0 || len(status.Routes) != 0 {
+				t.Errorf("short lines should have been skipped: %+v", status)
+			}
+		})
+	}
+}
+
 var serverInfoTestCases = []struct {
 	StatusVersionName        string
 	StatusFileContents       string
@@ -218,3 +327,218 @@ func TestServerInfoIsParsedCorrectly(t *testing.T) {
 	}
 
 }
+
+var routingTableTestCases = []struct {
+	StatusVersionName  string
+	StatusFileContents string
+	NumberOfRoutes     int
+	Route0Virtual      string
+	Route0CommonName   string
+	Route0RealAddress  string
+	Route0LastRef      time.Time
+}{
+	{"v1", connectedClientsV1, 4, "10.240.1.222", "user4", "1.2.3.7", parseDate("Wed Apr 22 12:36:56 2020")},
+	{"v2", connectedClientsV2, 2, "10.80.0.65", "test@localhost", "1.2.3.4", time.Unix(1588254940, 0)},
+	{"v3", connectedClientsV3, 2, "10.80.0.65", "test@localhost", "1.2.3.4", time.Unix(1588254940, 0)},
+}
+
+func TestRoutingTableIsParsedCorrectly(t *testing.T) {
+	for _, tt := range routingTableTestCases {
+		t.Run(tt.StatusVersionName, func(t *testing.T) {
+			status, e := parse(bufio.NewReader(strings.NewReader(tt.StatusFileContents)))
+			if e != nil {
+				t.Errorf("should have worked")
+			}
+			if len(status.Routes) != tt.NumberOfRoutes {
+				t.Fatalf("routes are not parsed correctly")
+			}
+			route := status.Routes[0]
+			if route.VirtualAddress != tt.Route0Virtual {
+				t.Errorf("virtual address is not parsed correctly")
+			}
+			if route.CommonName != tt.Route0CommonName {
+				t.Errorf("common name is not parsed correctly")
+			}
+			if route.RealAddress != tt.Route0RealAddress {
+				t.Errorf("real address is not parsed correctly")
+			}
+			if !tt.Route0LastRef.Equal(route.LastRef) {
+				t.Errorf("last ref is not parsed correctly")
+			}
+		})
+	}
+}
+
+func TestNoRoutesAreParsedWithoutConnectedClients(t *testing.T) {
+	for _, tt := range noConnectedClientsTestCases {
+		t.Run(tt.StatusVersionName, func(t *testing.T) {
+			status, _ := parse(bufio.NewReader(strings.NewReader(tt.StatusFileContents)))
+			if len(status.Routes) != 0 {
+				t.Errorf("routes are not parsed correctly")
+			}
+		})
+	}
+}
+
+const additionalColumnsV2 = `TITLE,OpenVPN 2.5.1 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [PKCS11] [MH/PKTINFO] [AEAD] built on Feb 24 2021
+TIME,2021-03-02 10:11:12,1614679872
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID,Data Channel Cipher
+CLIENT_LIST,test@localhost,1.2.3.4:54190,10.80.0.65,,3860,3688,2021-03-02 10:11:00,1614679860,test@localhost,0,0,AES-256-GCM
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+ROUTING_TABLE,10.80.0.65,test@localhost,1.2.3.4:54190,2021-03-02 10:11:10,1614679870
+GLOBAL_STATS,Max bcast/mcast queue length,0
+END
+`
+
+const reorderedColumnsV3 = `TITLE	OpenVPN 2.4.4 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [PKCS11] [MH/PKTINFO] [AEAD] built on May 14 2019
+TIME	Thu Apr 30 13:55:44 2020	1588254944
+HEADER	CLIENT_LIST	Real Address	Common Name	Bytes Sent	Bytes Received	Connected Since (time_t)
+CLIENT_LIST	1.2.3.4:54190	test@localhost	3688	3860	1588254938
+CLIENT_LIST	1.2.3.5:51053
+HEADER	ROUTING_TABLE	Common Name	Virtual Address	Last Ref (time_t)
+ROUTING_TABLE	test@localhost	10.80.0.65	1588254940
+GLOBAL_STATS	Max bcast/mcast queue length	0
+END
+`
+
+func TestAdditionalColumnsAreKeptAsAttributes(t *testing.T) {
+	status, e := parse(bufio.NewReader(strings.NewReader(additionalColumnsV2)))
+	if e != nil {
+		t.Fatalf("should have worked")
+	}
+	if len(status.ClientList) != 1 {
+		t.Fatalf("Clients are not parsed correctly")
+	}
+	client := status.ClientList[0]
+	if client.CommonName != "test@localhost" || client.BytesReceived != 3860 || client.BytesSent != 3688 {
+		t.Errorf("Clients are not parsed correctly")
+	}
+	if !client.ConnectedSince.Equal(time.Unix(1614679860, 0)) {
+		t.Errorf("connected since is not parsed correctly")
+	}
+	if client.Attributes["Data Channel Cipher"] != "AES-256-GCM" {
+		t.Errorf("unknown column was not kept as attribute")
+	}
+	if _, ok := client.Attributes["Common Name"]; ok {
+		t.Errorf("known column should not be kept as attribute")
+	}
+	if !status.Routes[0].LastRef.Equal(time.Unix(1614679870, 0)) {
+		t.Errorf("last ref is not parsed correctly")
+	}
+}
+
+func TestColumnsAreMappedByHeader(t *testing.T) {
+	status, e := parse(bufio.NewReader(strings.NewReader(reorderedColumnsV3)))
+	if e != nil {
+		t.Fatalf("should have worked")
+	}
+	if len(status.ClientList) != 2 {
+		t.Fatalf("Clients are not parsed correctly")
+	}
+	client := status.ClientList[0]
+	if client.CommonName != "test@localhost" || client.RealAddress != "1.2.3.4" {
+		t.Errorf("Clients are not parsed correctly")
+	}
+	if client.BytesReceived != 3860 || client.BytesSent != 3688 {
+		t.Errorf("bytes are not parsed correctly")
+	}
+	if !client.ConnectedSince.Equal(time.Unix(1588254938, 0)) {
+		t.Errorf("connected since is not parsed correctly")
+	}
+	if status.ClientList[1].RealAddress != "1.2.3.5" || status.ClientList[1].CommonName != "" {
+		t.Errorf("short rows are not parsed correctly")
+	}
+	route := status.Routes[0]
+	if route.CommonName != "test@localhost" || route.VirtualAddress != "10.80.0.65" {
+		t.Errorf("routes are not parsed correctly")
+	}
+	if !route.LastRef.Equal(time.Unix(1588254940, 0)) {
+		t.Errorf("last ref is not parsed correctly")
+	}
+}
+
+var clientConnectionInfoTestCases = []struct {
+	StatusVersionName  string
+	StatusFileContents string
+}{
+	{"v2", connectedClientsV2},
+	{"v3", connectedClientsV3},
+}
+
+func TestClientConnectionInfoIsParsedCorrectly(t *testing.T) {
+	for _, tt := range clientConnectionInfoTestCases {
+		t.Run(tt.StatusVersionName, func(t *testing.T) {
+			status, e := parse(bufio.NewReader(strings.NewReader(tt.StatusFileContents)))
+			if e != nil {
+				t.Fatalf("should have worked")
+			}
+			client := status.ClientList[1]
+			if client.VirtualAddress != "10.68.0.25" {
+				t.Errorf("virtual address is not parsed correctly")
+			}
+			if client.VirtualIPv6Address != "" {
+				t.Errorf("virtual ipv6 address is not parsed correctly")
+			}
+			if client.Username != "test1@localhost" {
+				t.Errorf("username is not parsed correctly")
+			}
+			if client.ClientID != "1" || client.PeerID != "1" {
+				t.Errorf("client id or peer id is not parsed correctly")
+			}
+			if len(client.Attributes) != 0 {
+				t.Errorf("no attributes expected for known columns")
+			}
+		})
+	}
+}
+
+const statistics = `OpenVPN STATISTICS
+Updated,Thu Apr 23 20:14:31 2020
+TUN/TAP read bytes,153789941
+TUN/TAP write bytes,308764078
+TCP/UDP read bytes,292806201
+TCP/UDP write bytes,180952337
+Auth read bytes,308770966
+pre-compress bytes,45388190
+post-compress bytes,45446864
+pre-decompress bytes,162596168
+post-decompress bytes,216965355
+END
+`
+
+func TestStatisticsAreParsedCorrectly(t *testing.T) {
+	status, e := parse(bufio.NewReader(strings.NewReader(statistics)))
+	if e != nil {
+		t.Fatalf("should have worked")
+	}
+	if !parseDate("Thu Apr 23 20:14:31 2020").Equal(status.UpdatedAt) {
+		t.Errorf("failed parsing updated at")
+	}
+	if status.Statistics == nil {
+		t.Fatalf("statistics are not parsed")
+	}
+	expected := Statistics{
+		TunTapReadBytes:     153789941,
+		TunTapWriteBytes:    308764078,
+		TCPUDPReadBytes:     292806201,
+		TCPUDPWriteBytes:    180952337,
+		AuthReadBytes:       308770966,
+		PreCompressBytes:    45388190,
+		PostCompressBytes:   45446864,
+		PreDecompressBytes:  162596168,
+		PostDecompressBytes: 216965355,
+	}
+	if *status.Statistics != expected {
+		t.Errorf("statistics are not parsed correctly: %+v", *status.Statistics)
+	}
+	if len(status.ClientList) != 0 {
+		t.Errorf("statistics should not contain clients")
+	}
+}
+
+func TestServerStatusHasNoStatistics(t *testing.T) {
+	status, _ := parse(bufio.NewReader(strings.NewReader(connectedClientsV2)))
+	if status.Statistics != nil {
+		t.Errorf("server status should not contain statistics")
+	}
+}
@@ -0,0 +1,83 @@
+package openvpn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"strings"
+)
+
+const (
+	topologyNet30  = "net30"
+	topologyP2P    = "p2p"
+	topologySubnet = "subnet"
+
+	defaultMaxClients = 1024
+	// maxPoolSize is the maximum size of an ifconfig pool supported by openvpn
+	maxPoolSize = 65536
+)
+
+// PoolSize returns the amount of clients the ifconfig pool of the server can hold the same
+// way openvpn derives it from the server, server-ipv6 and ifconfig-pool directives. It returns
+// 0 if the server has no pool.
+func (c *Config) PoolSize() int {
+	var start, end uint32
+	switch {
+	case c.IfconfigPoolStart != nil && c.IfconfigPoolEnd != nil:
+		start = ipv4ToUint32(c.IfconfigPoolStart)
+		end = ipv4ToUint32(c.IfconfigPoolEnd)
+	case c.Server != nil && !c.ServerNoPool:
+		network := ipv4ToUint32(c.Server.IP)
+		broadcast := network | ^ipv4ToUint32(net.IP(c.Server.Mask))
+		if c.Topology == topologySubnet {
+			start, end = network+2, broadcast-2
+		} else {
+			start, end = network+4, broadcast-4
+		}
+	case c.ServerIPv6 != nil:
+		// an IPv6 only pool is limited by the size of the network
+		ones, bits := c.ServerIPv6.Mask.Size()
+		if bits-ones >= 17 {
+			return maxPoolSize
+		}
+		return 1<<uint(bits-ones) - 2
+	default:
+		return 0
+	}
+	if end < start {
+		return 0
+	}
+	size := int(end-start) + 1
+	if c.Topology == topologyNet30 {
+		// every client occupies a /30 subnet
+		size /= 4
+	}
+	if size > maxPoolSize {
+		size = maxPoolSize
+	}
+	return size
+}
+
+// CountPersistedLeases returns the amount of addresses persisted in an ifconfig-pool-persist
+// file, which contains a common_name,address[,ipv6_address] line per lease.
+func CountPersistedLeases(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	leases := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) >= 2 && fields[0] != "" && fields[1] != "" {
+			leases++
+		}
+	}
+	return leases, scanner.Err()
+}
+
+func ipv4ToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
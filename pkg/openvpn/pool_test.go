@@ -0,0 +1,51 @@
+package openvpn
+
+import (
+	"strings"
+	"testing"
+)
+
+var poolSizeTestCases = []struct {
+	scenarioName string
+	config       string
+	expected     int
+}{
+	{"no pool", "", 0},
+	{"net30", "server 10.8.0.0 255.255.255.0", 62},
+	{"subnet", "topology subnet\nserver 10.8.0.0 255.255.255.0", 252},
+	{"p2p", "topology p2p\nserver 10.8.0.0 255.255.255.0", 248},
+	{"nopool", "server 10.8.0.0 255.255.255.0 nopool", 0},
+	{"ifconfig-pool", "topology subnet\nifconfig-pool 10.8.0.100 10.8.0.199", 100},
+	{"ifconfig-pool overrides server", "topology subnet\nserver 10.8.0.0 255.255.0.0\nifconfig-pool 10.8.0.100 10.8.0.199", 100},
+	{"large subnet", "topology subnet\nserver 10.0.0.0 255.0.0.0", 65536},
+	{"ipv6 only", "server-ipv6 fd00::/112", 65534},
+	{"large ipv6 only", "server-ipv6 fd00::/64", 65536},
+	{"ipv4 and ipv6", "topology subnet\nserver 10.8.0.0 255.255.255.0\nserver-ipv6 fd00::/64", 252},
+}
+
+func TestPoolSize(t *testing.T) {
+	for _, tt := range poolSizeTestCases {
+		t.Run(tt.scenarioName, func(t *testing.T) {
+			config, err := parseConfig(strings.NewReader(tt.config), "/conf")
+			if err != nil {
+				t.Fatalf("should have worked: %v", err)
+			}
+			if size := config.PoolSize(); size != tt.expected {
+				t.Errorf("expected pool size %d, got %d", tt.expected, size)
+			}
+		})
+	}
+}
+
+func TestCountPersistedLeases(t *testing.T) {
+	leases, err := CountPersistedLeases("../../example/ipp.txt")
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if leases != 3 {
+		t.Errorf("expected 3 leases, got %d", leases)
+	}
+	if _, err := CountPersistedLeases("../../example/missing.txt"); err == nil {
+		t.Errorf("should have failed on a missing file")
+	}
+}
@@ -0,0 +1,283 @@
+package openvpn
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DisconnectTotals accumulates the session totals reported by >CLIENT:DISCONNECT notifications
+type DisconnectTotals struct {
+	Sessions      float64
+	BytesReceived float64
+	BytesSent     float64
+	Duration      float64
+}
+
+// ClientTracker keeps an in-memory client table of a long-lived management interface
+// connection, which is seeded by status 3 and updated by the >CLIENT:ESTABLISHED,
+// >CLIENT:DISCONNECT and >BYTECOUNT_CLI real-time notifications. >CLIENT:CONNECT and
+// >CLIENT:REAUTH are only sent with --management-client-auth and are ignored, as OpenVPN
+// waits for a client-auth command the tracker never sends.
+type ClientTracker struct {
+	address           string
+	password          string
+	timeout           time.Duration
+	bytecountInterval time.Duration
+
+	mu               sync.Mutex
+	connected        bool
+	lastErr          error
+	serverInfo       ServerInfo
+	clients          map[string]*Client
+	disconnectTotals map[string]*DisconnectTotals
+	// pending collects the >CLIENT:ENV lines of the notification currently received
+	pending *clientNotification
+}
+
+type clientNotification struct {
+	event    string
+	clientID string
+	env      map[string]string
+}
+
+type trackerError struct {
+	s string
+}
+
+func (e *trackerError) Error() string {
+	return e.s
+}
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+// NewClientTracker returns a new ClientTracker for the management interface listening on address
+func NewClientTracker(address string, password string, timeout time.Duration, bytecountInterval time.Duration) *ClientTracker {
+	return &ClientTracker{
+		address:           address,
+		password:          password,
+		timeout:           timeout,
+		bytecountInterval: bytecountInterval,
+		clients:           make(map[string]*Client),
+		disconnectTotals:  make(map[string]*DisconnectTotals),
+		lastErr:           &trackerError{"not connected to management interface yet"},
+	}
+}
+
+// Run connects to the management interface and processes notifications until ctx is done.
+// Lost connections are re-established with an exponential backoff.
+func (t *ClientTracker) Run(ctx context.Context) {
+	backoff := minReconnectInterval
+	for {
+		started := time.Now()
+		err := t.session(ctx)
+		t.mu.Lock()
+		t.connected = false
+		t.lastErr = err
+		t.mu.Unlock()
+		if time.Since(started) > maxReconnectInterval {
+			backoff = minReconnectInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxReconnectInterval {
+			backoff = maxReconnectInterval
+		}
+	}
+}
+
+func (t *ClientTracker) session(ctx context.Context) error {
+	m, err := DialManagement(t.address, t.password, t.timeout)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.Close()
+		case <-done:
+			m.Close()
+		}
+	}()
+
+	// notifications arriving while seeding the client table are applied afterwards
+	var buffered []string
+	m.Notify = func(notification string) {
+		buffered = append(buffered, notification)
+	}
+	if err := m.Bytecount(t.bytecountInterval); err != nil {
+		return err
+	}
+	serverInfo, managementVersion, err := m.Version()
+	if err != nil {
+		return err
+	}
+	serverInfo.ManagementVersion = managementVersion
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.serverInfo = serverInfo
+	t.clients = make(map[string]*Client)
+	t.pending = nil
+	for i := range status.ClientList {
+		client := status.ClientList[i]
+		t.clients[client.ClientID] = &client
+	}
+	for _, notification := range buffered {
+		t.handle(notification)
+	}
+	t.connected = true
+	t.lastErr = nil
+	t.mu.Unlock()
+
+	m.Notify = func(notification string) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.handle(notification)
+	}
+	return m.Listen()
+}
+
+// handle processes a single notification, t.mu must be held
+func (t *ClientTracker) handle(notification string) {
+	switch {
+	case strings.HasPrefix(notification, ">BYTECOUNT_CLI:"):
+		fields := strings.Split(strings.TrimPrefix(notification, ">BYTECOUNT_CLI:"), ",")
+		if len(fields) != 3 {
+			return
+		}
+		if client, ok := t.clients[fields[0]]; ok {
+			client.BytesReceived, _ = strconv.ParseFloat(fields[1], 64)
+			client.BytesSent, _ = strconv.ParseFloat(fields[2], 64)
+		}
+	case strings.HasPrefix(notification, ">CLIENT:ENV,"):
+		if t.pending == nil {
+			return
+		}
+		env := strings.TrimPrefix(notification, ">CLIENT:ENV,")
+		if env == "END" {
+			t.apply(t.pending)
+			t.pending = nil
+			return
+		}
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) == 2 {
+			t.pending.env[kv[0]] = kv[1]
+		}
+	case strings.HasPrefix(notification, ">CLIENT:"):
+		fields := strings.Split(strings.TrimPrefix(notification, ">CLIENT:"), ",")
+		if len(fields) < 2 {
+			return
+		}
+		t.pending = &clientNotification{
+			event:    fields[0],
+			clientID: fields[1],
+			env:      make(map[string]string),
+		}
+	}
+}
+
+func (t *ClientTracker) apply(n *clientNotification) {
+	switch n.event {
+	case "ESTABLISHED":
+		t.clients[n.clientID] = clientFromEnv(n.clientID, n.env)
+	case "DISCONNECT":
+		client, ok := t.clients[n.clientID]
+		delete(t.clients, n.clientID)
+		commonName := n.env["common_name"]
+		if commonName == "" && ok {
+			commonName = client.CommonName
+		}
+		totals, ok := t.disconnectTotals[commonName]
+		if !ok {
+			totals = &DisconnectTotals{}
+			t.disconnectTotals[commonName] = totals
+		}
+		bytesReceived, _ := strconv.ParseFloat(n.env["bytes_received"], 64)
+		bytesSent, _ := strconv.ParseFloat(n.env["bytes_sent"], 64)
+		duration, _ := strconv.ParseFloat(n.env["time_duration"], 64)
+		totals.Sessions++
+		totals.BytesReceived += bytesReceived
+		totals.BytesSent += bytesSent
+		totals.Duration += duration
+	}
+}
+
+func clientFromEnv(clientID string, env map[string]string) *Client {
+	realAddress := env["trusted_ip"]
+	if realAddress == "" {
+		realAddress = env["trusted_ip6"]
+	}
+	connectedSince, _ := strconv.ParseInt(env["time_unix"], 10, 64)
+	return &Client{
+		CommonName:         env["common_name"],
+		RealAddress:        parseAddress(realAddress).Host,
+		RealPort:           env["trusted_port"],
+		VirtualAddress:     env["ifconfig_pool_remote_ip"],
+		VirtualIPv6Address: env["ifconfig_pool_remote_ip6"],
+		Username:           env["username"],
+		ClientID:           clientID,
+		ConnectedSince:     time.Unix(connectedSince, 0),
+		PeerInfo:           peerInfoFromEnv(env),
+	}
+}
+
+// peerInfoFromEnv returns all IV_* variables of a client env
+func peerInfoFromEnv(env map[string]string) map[string]string {
+	peerInfo := make(map[string]string)
+	for key, value := range env {
+		if strings.HasPrefix(key, "IV_") {
+			peerInfo[key] = value
+		}
+	}
+	return peerInfo
+}
+
+// Status returns a snapshot of the client table or an error if the tracker is not connected
+func (t *ClientTracker) Status() (*Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.connected {
+		return nil, t.lastErr
+	}
+	clients := make([]Client, 0, len(t.clients))
+	for _, client := range t.clients {
+		clients = append(clients, *client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		a, _ := strconv.Atoi(clients[i].ClientID)
+		b, _ := strconv.Atoi(clients[j].ClientID)
+		return a < b
+	})
+	return &Status{
+		ClientList: clients,
+		ServerInfo: t.serverInfo,
+		// the client table is kept up to date while connected
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// DisconnectTotals returns the accumulated session totals of disconnected clients per common name
+func (t *ClientTracker) DisconnectTotals() map[string]DisconnectTotals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals := make(map[string]DisconnectTotals, len(t.disconnectTotals))
+	for commonName, total := range t.disconnectTotals {
+		totals[commonName] = *total
+	}
+	return totals
+}
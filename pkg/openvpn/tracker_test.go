@@ -0,0 +1,135 @@
+package openvpn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const establishedNotification = `>CLIENT:ESTABLISHED,2
+>CLIENT:ENV,common_name=user2
+>CLIENT:ENV,trusted_ip=1.2.3.9
+>CLIENT:ENV,trusted_port=1194
+>CLIENT:ENV,ifconfig_pool_remote_ip=10.80.0.66
+>CLIENT:ENV,username=user2
+>CLIENT:ENV,time_unix=1588254950
+>CLIENT:ENV,IV_VER=2.4.6
+>CLIENT:ENV,IV_PLAT=mac
+>CLIENT:ENV,IV_GUI_VER=net.tunnelblick.tunnelblick_5200_3.7.8__build_5200
+>CLIENT:ENV,IV_SSL=OpenSSL_1.0.2q__20_Nov_2018
+>CLIENT:ENV,END
+`
+
+const disconnectNotification = `>CLIENT:DISCONNECT,1
+>CLIENT:ENV,common_name=test1@localhost
+>CLIENT:ENV,bytes_received=4000
+>CLIENT:ENV,bytes_sent=5000
+>CLIENT:ENV,time_duration=60
+>CLIENT:ENV,END
+`
+
+// clientAuthNotifications are only sent with --management-client-auth
+const clientAuthNotifications = `>CLIENT:CONNECT,3,1
+>CLIENT:ENV,common_name=user3
+>CLIENT:ENV,IV_PLAT=win
+>CLIENT:ENV,END
+>CLIENT:REAUTH,2,2
+>CLIENT:ENV,common_name=user2
+>CLIENT:ENV,IV_PLAT=win
+>CLIENT:ENV,END
+`
+
+func handleNotifications(tracker *ClientTracker, notifications string) {
+	for _, line := range strings.Split(strings.TrimSpace(notifications), "\n") {
+		tracker.handle(line)
+	}
+}
+
+func TestClientTrackerHandlesNotifications(t *testing.T) {
+	tracker := NewClientTracker("", "", time.Second, time.Second)
+	tracker.connected = true
+	tracker.clients["1"] = &Client{CommonName: "test1@localhost", ClientID: "1"}
+
+	handleNotifications(tracker, establishedNotification)
+	handleNotifications(tracker, clientAuthNotifications)
+	handleNotifications(tracker, ">BYTECOUNT_CLI:2,1000,2000\n>BYTECOUNT_CLI:99,1,1")
+
+	status, err := tracker.Status()
+	if err != nil {
+		t.Fatalf("should have worked: %v", err)
+	}
+	if len(status.ClientList) != 2 {
+		t.Fatalf("Clients are not tracked correctly, connecting clients should be ignored")
+	}
+	client := status.ClientList[1]
+	if client.CommonName != "user2" || client.RealAddress != "1.2.3.9" || client.RealPort != "1194" {
+		t.Errorf("established client is not parsed correctly: %+v", client)
+	}
+	if client.VirtualAddress != "10.80.0.66" || client.Username != "user2" || client.ClientID != "2" {
+		t.Errorf("established client is not parsed correctly: %+v", client)
+	}
+	if !client.ConnectedSince.Equal(time.Unix(1588254950, 0)) {
+		t.Errorf("connected since is not parsed correctly")
+	}
+	if client.BytesReceived != 1000 || client.BytesSent != 2000 {
+		t.Errorf("bytecount is not applied correctly")
+	}
+	if client.PeerInfo["IV_VER"] != "2.4.6" || client.PeerInfo["IV_PLAT"] != "mac" || len(client.PeerInfo) != 4 {
+		t.Errorf("peer info is not tracked correctly, reauthentications should be ignored: %v", client.PeerInfo)
+	}
+	if _, ok := client.PeerInfo["common_name"]; ok {
+		t.Errorf("peer info should only contain IV_ variables")
+	}
+
+	handleNotifications(tracker, disconnectNotification)
+	status, _ = tracker.Status()
+	if len(status.ClientList) != 1 || status.ClientList[0].ClientID != "2" {
+		t.Errorf("disconnected client was not removed")
+	}
+	totals := tracker.DisconnectTotals()["test1@localhost"]
+	if totals != (DisconnectTotals{Sessions: 1, BytesReceived: 4000, BytesSent: 5000, Duration: 60}) {
+		t.Errorf("disconnect totals are not accumulated correctly: %+v", totals)
+	}
+}
+
+func TestClientTrackerIsNotConnected(t *testing.T) {
+	tracker := NewClientTracker("", "", time.Second, time.Second)
+	if _, err := tracker.Status(); err == nil {
+		t.Errorf("should have failed without connection")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientTrackerRun(t *testing.T) {
+	f := newFakeManagement(t, "tcp", "127.0.0.1:0", "", defaultManagementResponses())
+	tracker := NewClientTracker(f.Address(), "", time.Second, 5*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.Run(ctx)
+
+	waitFor(t, func() bool {
+		status, err := tracker.Status()
+		return err == nil && len(status.ClientList) == 2
+	})
+	status, _ := tracker.Status()
+	if status.ServerInfo.Version != "2.4.4" || status.ServerInfo.ManagementVersion != "1" {
+		t.Errorf("version is not parsed correctly: %+v", status.ServerInfo)
+	}
+
+	f.Notify(">BYTECOUNT_CLI:0,5000,6000\n")
+	f.Notify(disconnectNotification)
+	waitFor(t, func() bool {
+		status, _ := tracker.Status()
+		return len(status.ClientList) == 1 && status.ClientList[0].BytesReceived == 5000
+	})
+}
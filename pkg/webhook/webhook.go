@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+// namespace defines the namespace of the webhook metrics
+const namespace = "openvpn"
+
+// Event types
+const (
+	EventConnect    = "connect"
+	EventDisconnect = "disconnect"
+)
+
+// Event is the JSON document posted to the webhook URLs when a client session starts or ends
+type Event struct {
+	Type               string    `json:"type"`
+	Time               time.Time `json:"time"`
+	Server             string    `json:"server"`
+	CommonName         string    `json:"common_name"`
+	Username           string    `json:"username,omitempty"`
+	RealAddress        string    `json:"real_address"`
+	RealPort           string    `json:"real_port,omitempty"`
+	VirtualAddress     string    `json:"virtual_address,omitempty"`
+	VirtualIPv6Address string    `json:"virtual_ipv6_address,omitempty"`
+	ConnectedSince     time.Time `json:"connected_since"`
+	// BytesReceived and BytesSent are the totals of the session as last seen
+	BytesReceived float64 `json:"bytes_received"`
+	BytesSent     float64 `json:"bytes_sent"`
+}
+
+// NewEvent returns the event of type for a client session of server
+func NewEvent(eventType string, server string, client openvpn.Client, now time.Time) Event {
+	return Event{
+		Type:               eventType,
+		Time:               now,
+		Server:             server,
+		CommonName:         client.CommonName,
+		Username:           client.Username,
+		RealAddress:        client.RealAddress,
+		RealPort:           client.RealPort,
+		VirtualAddress:     client.VirtualAddress,
+		VirtualIPv6Address: client.VirtualIPv6Address,
+		ConnectedSince:     client.ConnectedSince,
+		BytesReceived:      client.BytesReceived,
+		BytesSent:          client.BytesSent,
+	}
+}
+
+// Notifier posts events to webhook URLs. Events are queued in a bounded queue and delivered
+// in order, failed deliveries are retried with an exponential backoff. Events are dropped
+// when the queue is full.
+type Notifier struct {
+	logger  log.Logger
+	urls    []string
+	retries int
+	backoff time.Duration
+	client  *http.Client
+	queue   chan Event
+	Events  *prometheus.CounterVec
+}
+
+// Delivery results of Notifier.Events
+const (
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+	resultDropped   = "dropped"
+)
+
+// NewNotifier returns a new Notifier for the webhook URLs which queues up to queueSize events
+// and retries every delivery up to retries times
+func NewNotifier(logger log.Logger, urls []string, queueSize int, retries int, timeout time.Duration) *Notifier {
+	return &Notifier{
+		logger:  logger,
+		urls:    urls,
+		retries: retries,
+		backoff: time.Second,
+		client:  &http.Client{Timeout: timeout},
+		queue:   make(chan Event, queueSize),
+		Events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: prometheus.BuildFQName(namespace, "exporter", "webhook_events_total"),
+				Help: "Amount of webhook deliveries by result (delivered, failed or dropped)",
+			},
+			[]string{"result"},
+		),
+	}
+}
+
+// Notify queues an event for delivery, it does not block and drops the event if the queue is full
+func (n *Notifier) Notify(event Event) {
+	select {
+	case n.queue <- event:
+	default:
+		level.Warn(n.logger).Log(
+			"msg", "webhook queue is full, dropping event",
+			"type", event.Type,
+			"server", event.Server,
+			"commonName", event.CommonName,
+		)
+		n.Events.WithLabelValues(resultDropped).Add(float64(len(n.urls)))
+	}
+}
+
+// Run delivers the queued events until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.queue:
+			body, err := json.Marshal(event)
+			if err != nil {
+				level.Error(n.logger).Log("msg", "error encoding webhook event", "err", err)
+				continue
+			}
+			for _, webhookURL := range n.urls {
+				n.deliver(ctx, webhookURL, body)
+			}
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, webhookURL string, body []byte) {
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		err := n.post(ctx, webhookURL, body)
+		if err == nil {
+			n.Events.WithLabelValues(resultDelivered).Inc()
+			return
+		}
+		if attempt >= n.retries {
+			level.Warn(n.logger).Log(
+				"msg", "error delivering webhook event",
+				"host", redact(webhookURL),
+				"attempts", attempt+1,
+				"err", err,
+			)
+			n.Events.WithLabelValues(resultFailed).Inc()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, webhookURL string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// redact returns the scheme and host of a webhook URL, which often contains a secret token
+func redact(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Describe sends the super-set of all possible descriptors of metrics collected by this Collector.
+func (n *Notifier) Describe(ch chan<- *prometheus.Desc) {
+	n.Events.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (n *Notifier) Collect(ch chan<- prometheus.Metric) {
+	n.Events.Collect(ch)
+}
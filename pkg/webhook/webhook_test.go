@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/patrickjahns/openvpn_exporter/pkg/openvpn"
+)
+
+func TestNotifierRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	n := NewNotifier(log.NewNopLogger(), []string{server.URL}, 10, 3, time.Second)
+	n.backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	client := openvpn.Client{CommonName: "admin", RealAddress: "1.2.3.4", VirtualAddress: "10.8.0.2", BytesReceived: 10}
+	n.Notify(NewEvent(EventDisconnect, "test", client, time.Unix(100, 0)))
+	select {
+	case event := <-received:
+		if event.Type != EventDisconnect || event.Server != "test" || event.CommonName != "admin" || event.RealAddress != "1.2.3.4" || event.BytesReceived != 10 {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event was not delivered")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(n.Events.WithLabelValues(resultDelivered)) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if value := testutil.ToFloat64(n.Events.WithLabelValues(resultDelivered)); value != 1 {
+		t.Errorf("expected 1 delivered event, got %v", value)
+	}
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := NewNotifier(log.NewNopLogger(), []string{server.URL}, 10, 2, time.Second)
+	n.backoff = time.Millisecond
+	n.deliver(context.Background(), server.URL, []byte("{}"))
+	if value := testutil.ToFloat64(n.Events.WithLabelValues(resultFailed)); value != 1 {
+		t.Errorf("expected 1 failed event, got %v", value)
+	}
+}
+
+func TestNotifierDropsEvents(t *testing.T) {
+	n := NewNotifier(log.NewNopLogger(), []string{"http://a", "http://b"}, 1, 0, time.Second)
+	n.Notify(Event{Type: EventConnect})
+	n.Notify(Event{Type: EventConnect})
+	if value := testutil.ToFloat64(n.Events.WithLabelValues(resultDropped)); value != 2 {
+		t.Errorf("expected the event to be dropped for both urls, got %v", value)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	if host := redact("https://hooks.example.com/services/secret?token=x"); host != "https://hooks.example.com" {
+		t.Errorf("unexpected redacted url %s", host)
+	}
+}